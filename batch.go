@@ -0,0 +1,105 @@
+package jgh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RESTCall holds the arguments to a single RESTRequest, for use with
+// RESTBatch
+type RESTCall struct {
+	Method  string
+	URL     string
+	User    string
+	Pass    string
+	Headers map[string]string
+	Input   interface{}
+	// Output, if not nil, must be a pointer. It is filled in with the
+	// response, just like the outputPtr argument of RESTRequest.
+	Output interface{}
+}
+
+// RESTResult is the outcome of a single RESTCall
+type RESTResult struct {
+	Status     int
+	Reflection bool
+	// Err is set if the request panicked, returned an error status
+	// (see StatusError), or was never made because the batch was cancelled
+	Err error
+}
+
+// StatusError is the error for a response with a 4xx or 5xx status code.
+// Its message starts with the status code, so Status can read it back.
+type StatusError struct {
+	Method string
+	URL    string
+	Status int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("%d %s (%s %s)", e.Status, http.StatusText(e.Status), e.Method, e.URL)
+}
+
+// HTTPStatus returns the status code of the response
+func (e StatusError) HTTPStatus() int {
+	return e.Status
+}
+
+// RESTBatch makes every call in calls using RESTRequestContext, with at
+// most concurrency requests in flight at once (concurrency < 1 means one
+// at a time). Results are returned in the same order as calls.
+//
+// If failFast is true, the first failure cancels every call that has not
+// finished yet. Otherwise every call is attempted. Cancelling ctx stops
+// the batch either way. Calls that were never made have an Err wrapping
+// the context's error. If any call failed, a *BatchError is returned along
+// with the results.
+func RESTBatch(ctx context.Context, client *http.Client, calls []RESTCall, concurrency int, failFast bool) ([]RESTResult, error) {
+	results, err := ParallelMap(ctx, calls, concurrency, failFast, func(ctx context.Context, call RESTCall) (RESTResult, error) {
+		result := restCall(ctx, client, call)
+		return result, result.Err
+	})
+
+	// copy errors for calls that were never made into their results
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		for i, callErr := range batchErr.Failed {
+			results[i].Err = callErr
+		}
+	}
+	return results, err
+}
+
+// restCall makes a single call, turning panics and error statuses into
+// errors
+func restCall(ctx context.Context, client *http.Client, call RESTCall) (result RESTResult) {
+	defer func() {
+		panicMsg := recover()
+		if panicMsg == nil {
+			return
+		}
+		err := panicToError(panicMsg)
+		// HTTPRequest panics with a plain string, so reattach the
+		// cancellation reason to keep errors.Is(err, context.Canceled) working
+		if ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+			err = fmt.Errorf("%s: %w", err, ctx.Err())
+		}
+		result.Err = err
+	}()
+
+	var err error
+	result.Status, result.Reflection, err = restRequest(
+		ctx, client, call.Method, call.URL, call.User, call.Pass,
+		call.Headers, call.Input, call.Output,
+	)
+	// an error status matters more than an error body that isn't JSON
+	if result.Status >= 400 {
+		result.Err = StatusError{Method: strings.ToUpper(call.Method), URL: call.URL, Status: result.Status}
+	} else if err != nil {
+		result.Err = err
+	}
+	return
+}
@@ -0,0 +1,146 @@
+package jgh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRESTBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if strings.HasSuffix(r.URL.Path, "/13") {
+			w.WriteHeader(404)
+		}
+		fmt.Fprintf(w, `{"path":%q}`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	type output struct {
+		Path string `json:"path"`
+	}
+	outputs := make([]output, 20)
+	calls := make([]RESTCall, 20)
+	for i := range calls {
+		calls[i] = RESTCall{Method: "GET", URL: fmt.Sprintf("%s/items/%d", server.URL, i), Output: &outputs[i]}
+	}
+
+	results, err := RESTBatch(context.Background(), nil, calls, 4, false)
+	if maxInFlight > 4 {
+		t.Errorf("%d requests were in flight at once", maxInFlight)
+	}
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a BatchError, got %v", err)
+	}
+	expect(t, 13, batchErr.First, "first failed index")
+	expect(t, 1, len(batchErr.Failed), "number of failures")
+	var statusErr StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != 404 {
+		t.Error("BatchError does not unwrap to a StatusError")
+	}
+	for i, result := range results {
+		if i != 13 && (result.Err != nil || result.Status != 200) {
+			t.Errorf("Call %d failed: %v", i, result.Err)
+		}
+		expect(t, fmt.Sprintf("/items/%d", i), outputs[i].Path, "output path")
+	}
+}
+
+func TestRESTBatchFailFast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+		w.Write([]byte("{}")) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	calls := make([]RESTCall, 50)
+	for i := range calls {
+		calls[i] = RESTCall{Method: "GET", URL: server.URL}
+	}
+	results, err := RESTBatch(context.Background(), nil, calls, 2, true)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !errors.Is(results[len(results)-1].Err, context.Canceled) {
+		t.Errorf("Last call was not cancelled: %v", results[len(results)-1].Err)
+	}
+}
+
+func TestRESTBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := RESTBatch(ctx, nil, []RESTCall{{Method: "GET", URL: "http://example.invalid/"}}, 1, false)
+	if err == nil || !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("Cancelled batch made a request: %v", results[0].Err)
+	}
+}
+
+func TestRESTBatchSharedHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(400)
+		}
+		io.Copy(w, r.Body) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	// one headers map for every call, which is how batches are usually
+	// built; run with -race to check it isn't written to
+	headers := map[string]string{"X-Token": "abc"}
+	calls := make([]RESTCall, 32)
+	for i := range calls {
+		calls[i] = RESTCall{Method: "POST", URL: server.URL, Headers: headers, Input: map[string]int{"n": i}}
+	}
+	results, err := RESTBatch(context.Background(), nil, calls, 16, false)
+	if err != nil {
+		t.Fatalf("Batch failed: %s", err)
+	}
+	for i, result := range results {
+		if !result.Reflection {
+			t.Errorf("Call %d did not get its input back", i)
+		}
+	}
+	if len(headers) != 1 {
+		t.Errorf("Caller's headers were changed to %v", headers)
+	}
+}
+
+func TestRESTBatchNonJSONError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(502)
+		fmt.Fprint(w, "<html><body>Bad Gateway</body></html>")
+	}))
+	defer server.Close()
+
+	var output struct{ Path string }
+	results, _ := RESTBatch(context.Background(), nil, []RESTCall{{Method: "GET", URL: server.URL, Output: &output}}, 1, false)
+	if results[0].Status != 502 {
+		t.Errorf("Status is %d, expected 502", results[0].Status)
+	}
+	var statusErr StatusError
+	if !errors.As(results[0].Err, &statusErr) || statusErr.Status != 502 {
+		t.Errorf("Expected a StatusError for 502, got %v", results[0].Err)
+	}
+	if status, err := StatusOf(results[0].Err); err != nil || status != 502 {
+		t.Errorf("StatusOf gave %d, %v", status, err)
+	}
+}
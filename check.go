@@ -0,0 +1,124 @@
+package jgh
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// The functions in this file are error returning versions of Expect,
+// PanicOnErr and RenameErr, for code that can't afford to panic. Instead
+// of logging where the error happened, they record it in a CallerError.
+
+// CallerError is an error annotated with the file and line of the code
+// that checked it, and optionally a new message
+type CallerError struct {
+	Err  error
+	Msg  string
+	File string
+	Line int
+}
+
+func (e *CallerError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("%s line %d: %s: %s", e.File, e.Line, e.Msg, e.Err)
+	}
+	return fmt.Sprintf("%s line %d: %s", e.File, e.Line, e.Err)
+}
+
+// Unwrap returns the original error
+func (e *CallerError) Unwrap() error {
+	return e.Err
+}
+
+// newCallerError wraps err with the location of the caller skip frames
+// above newCallerError's caller
+func newCallerError(err error, msg string, skip int) *CallerError {
+	_, filename, line, _ := runtime.Caller(skip + 2)
+	return &CallerError{Err: err, Msg: msg, File: filename, Line: line}
+}
+
+// CheckErr is the error returning version of PanicOnErr. It returns nil if
+// err is nil, otherwise err wrapped in a *CallerError pointing at the
+// line that called CheckErr.
+func CheckErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return newCallerError(err, "", 0)
+}
+
+// CheckRenameErr is the error returning version of RenameErr. Unlike
+// RenameErr, the original error is kept (and can be reached with
+// errors.Is and errors.As), with newErrMsg added in front of it.
+func CheckRenameErr(err error, newErrMsg string) error {
+	if err == nil {
+		return nil
+	}
+	return newCallerError(err, newErrMsg, 0)
+}
+
+// ExpectationError is the error for a value that did not match what was
+// expected
+type ExpectationError struct {
+	Name     string
+	Expected interface{}
+	Got      interface{}
+	// Diffs lists each difference between Expected and Got, as returned
+	// by Diff
+	Diffs []string
+}
+
+// compare returns nil if input matches expected, otherwise an
+// *ExpectationError. Without opts this is just reflect.DeepEqual.
+func compare(expected interface{}, input interface{}, name string, opts []CompareOption) *ExpectationError {
+	if len(opts) == 0 && reflect.DeepEqual(input, expected) {
+		return nil
+	}
+	diffs := Diff(name, expected, input, opts...)
+	if len(opts) > 0 && len(diffs) == 0 {
+		return nil
+	}
+	return &ExpectationError{
+		Name:     name,
+		Expected: expected,
+		Got:      input,
+		Diffs:    diffs,
+	}
+}
+
+// Error lists the differences between the values. If the only difference
+// is the whole value (for example two different numbers), it reads like
+// the message from Expect always used to.
+func (e *ExpectationError) Error() string {
+	if len(e.Diffs) == 0 || (len(e.Diffs) == 1 && strings.HasPrefix(e.Diffs[0], e.Name+": expected ")) {
+		return fmt.Sprintf("Expected %s to be %v, got %v", e.Name, e.Expected, e.Got)
+	}
+	return fmt.Sprintf("Expected %s to be equal, differences:\n\t%s", e.Name, strings.Join(e.Diffs, "\n\t"))
+}
+
+// CheckExpect is the error returning version of Expect. It returns nil if
+// input and expected are deeply equal (as relaxed by opts), otherwise an
+// *ExpectationError wrapped in a *CallerError.
+func CheckExpect(expected interface{}, input interface{}, name string, opts ...CompareOption) error {
+	expectationErr := compare(expected, input, name, opts)
+	if expectationErr == nil {
+		return nil
+	}
+	return newCallerError(expectationErr, "", 0)
+}
+
+// Must returns value if err is nil, and otherwise panics the same way
+// PanicOnErr does. It is for script style code calling functions that
+// return a value and an error:
+//
+//	contents := Must(ioutil.ReadFile("config.json"))
+func Must[T any](value T, err error) T {
+	if err != nil {
+		_, filename, line, _ := runtime.Caller(1)
+		Logger.Printf("Panic at %s line %d: %s\n", filename, line, err)
+		panic(err)
+	}
+	return value
+}
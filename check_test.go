@@ -0,0 +1,60 @@
+package jgh
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckErr(t *testing.T) {
+	if CheckErr(nil) != nil {
+		t.Error("CheckErr(nil) returned an error")
+	}
+
+	err := CheckErr(io.EOF)
+	var callerErr *CallerError
+	if !errors.As(err, &callerErr) {
+		t.Fatalf("Expected a CallerError, got %v", err)
+	}
+	if !strings.HasSuffix(callerErr.File, "check_test.go") || callerErr.Line == 0 {
+		t.Errorf("CallerError points at %s line %d", callerErr.File, callerErr.Line)
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Error("CallerError does not unwrap to the original error")
+	}
+
+	err = CheckRenameErr(io.EOF, "reading config")
+	if !strings.Contains(err.Error(), "reading config: EOF") || !errors.Is(err, io.EOF) {
+		t.Errorf("Renamed error lost information: %v", err)
+	}
+}
+
+func TestCheckExpect(t *testing.T) {
+	if CheckExpect(7, 7, "seven") != nil {
+		t.Error("CheckExpect failed on equal values")
+	}
+	err := CheckExpect(7, 8, "seven")
+	var expectationErr *ExpectationError
+	if !errors.As(err, &expectationErr) {
+		t.Fatalf("Expected an ExpectationError, got %v", err)
+	}
+	expect(t, 8, expectationErr.Got, "Got")
+}
+
+func TestMust(t *testing.T) {
+	expect(t, 42, Must(strconv.Atoi("42")), "Must(strconv.Atoi(\"42\"))")
+
+	success, msg := Try(0, 1, false, "", func() bool {
+		Must(strconv.Atoi("forty-two"))
+		return true
+	})
+	if success {
+		t.Error("Must did not panic")
+	}
+	var numErr *strconv.NumError
+	if err, ok := msg.(error); !ok || !errors.As(err, &numErr) {
+		t.Error("Must did not panic with the original error")
+	}
+}
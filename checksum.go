@@ -0,0 +1,231 @@
+package jgh
+
+import (
+	"bufio"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrChecksumMismatch is the error in a ChecksumResult for a file whose
+// contents don't match its checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrInvalidChecksumLine is returned (wrapped with the line number) by
+// ParseChecksums for a line it can't read
+var ErrInvalidChecksumLine = errors.New("invalid checksum line")
+
+// ChecksumReader hashes everything read from r with each of hashes, in a
+// single pass, for example:
+//
+//	sums, err := ChecksumReader(resp.Body, crypto.MD5, crypto.SHA256)
+//	fmt.Println(sums[crypto.SHA256].Hex())
+func ChecksumReader(r io.Reader, hashes ...crypto.Hash) (map[crypto.Hash]Digest, error) {
+	writers := make([]io.Writer, len(hashes))
+	states := make(map[crypto.Hash]interface{ Sum([]byte) []byte }, len(hashes))
+	for i, hash := range hashes {
+		h := hash.New()
+		writers[i] = h
+		states[hash] = h
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, err
+	}
+	sums := make(map[crypto.Hash]Digest, len(hashes))
+	for hash, h := range states {
+		sums[hash] = h.Sum(nil)
+	}
+	return sums, nil
+}
+
+// ChecksumFile is ChecksumReader for the file at path
+func ChecksumFile(path string, hashes ...crypto.Hash) (map[crypto.Hash]Digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+	return ChecksumReader(f, hashes...)
+}
+
+// ChecksumEntry is one line of a checksum file
+type ChecksumEntry struct {
+	Filename string
+	Hash     crypto.Hash
+	Digest   Digest
+}
+
+// String formats the entry the way sha256sum and md5sum do
+func (e ChecksumEntry) String() string {
+	if strings.ContainsAny(e.Filename, "\\\n") {
+		escaped := strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(e.Filename)
+		return "\\" + e.Digest.Hex() + "  " + escaped
+	}
+	return e.Digest.Hex() + "  " + e.Filename
+}
+
+// checksumHashes are the algorithms ParseChecksums knows, by the names
+// used in BSD style lines
+var checksumHashes = map[string]crypto.Hash{
+	"MD5":    crypto.MD5,
+	"SHA1":   crypto.SHA1,
+	"SHA224": crypto.SHA224,
+	"SHA256": crypto.SHA256,
+	"SHA384": crypto.SHA384,
+	"SHA512": crypto.SHA512,
+}
+
+// ParseChecksums reads a checksum file as written by sha256sum, md5sum and
+// friends ("<hex digest>  <filename>", with " *" before binary files), or
+// in their --tag format ("SHA256 (<filename>) = <hex digest>"). Without a
+// tag, the algorithm is worked out from the length of the digest. Blank
+// lines and lines starting with # are skipped.
+func ParseChecksums(r io.Reader) ([]ChecksumEntry, error) {
+	var entries []ChecksumEntry
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, ok := parseChecksumLine(line)
+		if !ok {
+			return entries, fmt.Errorf("line %d: %w", lineNumber, ErrInvalidChecksumLine)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func parseChecksumLine(line string) (entry ChecksumEntry, ok bool) {
+	// a leading backslash means the filename is escaped
+	escaped := strings.HasPrefix(line, "\\")
+	line = strings.TrimPrefix(line, "\\")
+
+	var digest string
+	if name, rest, found := strings.Cut(line, " ("); found && checksumHashes[name] != 0 {
+		split := strings.LastIndex(rest, ") = ")
+		if split < 0 {
+			return entry, false
+		}
+		entry.Hash = checksumHashes[name]
+		entry.Filename, digest = rest[:split], rest[split+4:]
+	} else {
+		digest, entry.Filename, found = strings.Cut(line, " ")
+		if !found || len(entry.Filename) < 2 || (entry.Filename[0] != ' ' && entry.Filename[0] != '*') {
+			return entry, false
+		}
+		entry.Filename = entry.Filename[1:]
+		for _, hash := range checksumHashes {
+			if len(digest) == hash.Size()*2 {
+				entry.Hash = hash
+			}
+		}
+	}
+	if escaped {
+		entry.Filename = unescapeChecksumFilename(entry.Filename)
+	}
+
+	if entry.Hash == 0 || len(digest) != entry.Hash.Size()*2 || entry.Filename == "" {
+		return entry, false
+	}
+	decoded, ok := decodeDigest(digest, entry.Hash.Size())
+	if !ok {
+		return entry, false
+	}
+	entry.Digest = decoded
+	return entry, true
+}
+
+func unescapeChecksumFilename(filename string) string {
+	return strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(filename)
+}
+
+// ChecksumResult is the outcome of checking one entry of a checksum file
+type ChecksumResult struct {
+	ChecksumEntry
+	// Actual is the digest of the file, if it could be read
+	Actual Digest
+	// Err is nil if the file matched, ErrChecksumMismatch if it didn't, or
+	// the error from reading it
+	Err error
+}
+
+// ChecksumError is returned by VerifyChecksums when any file fails
+type ChecksumError struct {
+	Total  int
+	Failed []ChecksumResult
+}
+
+func (e *ChecksumError) Error() string {
+	failures := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		failures[i] = result.Filename + ": " + result.Err.Error()
+	}
+	return fmt.Sprintf("%d of %d files failed verification: %s", len(e.Failed), e.Total, strings.Join(failures, "; "))
+}
+
+// VerifyChecksums checks every file listed in the checksum file at path,
+// like sha256sum -c. Relative filenames are relative to the directory of
+// the checksum file. It returns a result for each entry, and a
+// *ChecksumError if any of them failed. Each file is only read once, even
+// if it is listed with several algorithms.
+func VerifyChecksums(path string) ([]ChecksumResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+	return VerifyChecksumsReader(f, filepath.Dir(path))
+}
+
+// VerifyChecksumsReader is VerifyChecksums for a checksum file read from
+// r, with filenames relative to dir
+func VerifyChecksumsReader(r io.Reader, dir string) ([]ChecksumResult, error) {
+	entries, err := ParseChecksums(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// work out which algorithms each file needs
+	var filenames []string
+	hashes := make(map[string][]crypto.Hash)
+	for _, entry := range entries {
+		if _, seen := hashes[entry.Filename]; !seen {
+			filenames = append(filenames, entry.Filename)
+		}
+		hashes[entry.Filename] = append(hashes[entry.Filename], entry.Hash)
+	}
+	sums := make(map[string]map[crypto.Hash]Digest, len(filenames))
+	errs := make(map[string]error)
+	for _, filename := range filenames {
+		path := filename
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		sums[filename], errs[filename] = ChecksumFile(path, hashes[filename]...)
+	}
+
+	results := make([]ChecksumResult, len(entries))
+	checksumErr := &ChecksumError{Total: len(entries)}
+	for i, entry := range entries {
+		results[i] = ChecksumResult{ChecksumEntry: entry, Err: errs[entry.Filename]}
+		if results[i].Err == nil {
+			results[i].Actual = sums[entry.Filename][entry.Hash]
+			if !results[i].Actual.Equal(entry.Digest) {
+				results[i].Err = ErrChecksumMismatch
+			}
+		}
+		if results[i].Err != nil {
+			checksumErr.Failed = append(checksumErr.Failed, results[i])
+		}
+	}
+	if len(checksumErr.Failed) > 0 {
+		return results, checksumErr
+	}
+	return results, nil
+}
@@ -0,0 +1,85 @@
+package jgh
+
+import (
+	"crypto"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksumReader(t *testing.T) {
+	sums, err := ChecksumReader(strings.NewReader("Hello World"), crypto.MD5, crypto.SHA1, crypto.SHA256)
+	expectNoErr(t, err)
+	expect(t, MD5("Hello World"), sums[crypto.MD5].Hex(), "MD5")
+	expect(t, SHA1("Hello World"), sums[crypto.SHA1].Hex(), "SHA-1")
+	expect(t, SHA256("Hello World"), sums[crypto.SHA256].Hex(), "SHA-256")
+
+	_, err = ChecksumFile(filepath.Join(t.TempDir(), "missing"), crypto.SHA256)
+	expect(t, true, errors.Is(err, os.ErrNotExist), "missing file is ErrNotExist")
+}
+
+func TestParseChecksums(t *testing.T) {
+	entries, err := ParseChecksums(strings.NewReader(strings.Join([]string{
+		"# comment",
+		"a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e  hello.txt",
+		"b10a8db164e0754105b7a99be72e3fe5 *binary file.bin",
+		"",
+		"SHA1 (tagged (1).txt) = 0a4d55a8d778e5022fab701977c5d840bbc486d0\r",
+		"\\d41d8cd98f00b204e9800998ecf8427e  back\\\\slash",
+	}, "\n")))
+	expectNoErr(t, err)
+	expect(t, 4, len(entries), "number of entries")
+	expect(t, ChecksumEntry{"hello.txt", crypto.SHA256, HashString(crypto.SHA256, "Hello World")}, entries[0], "untagged SHA-256")
+	expect(t, ChecksumEntry{"binary file.bin", crypto.MD5, HashString(crypto.MD5, "Hello World")}, entries[1], "binary MD5")
+	expect(t, ChecksumEntry{"tagged (1).txt", crypto.SHA1, HashString(crypto.SHA1, "Hello World")}, entries[2], "tagged SHA-1")
+	expect(t, `back\slash`, entries[3].Filename, "escaped filename")
+	expect(t, `\d41d8cd98f00b204e9800998ecf8427e  back\\slash`, entries[3].String(), "formatted escaped entry")
+	expect(t, "a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e  hello.txt", entries[0].String(), "formatted entry")
+
+	for _, line := range []string{
+		"a591a6d4  short.txt",
+		"a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e",
+		"a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146z  bad.txt",
+		"SHA256 (file.txt) = b10a8db164e0754105b7a99be72e3fe5",
+	} {
+		_, err := ParseChecksums(strings.NewReader("# header\n" + line))
+		if !errors.Is(err, ErrInvalidChecksumLine) || !strings.HasPrefix(err.Error(), "line 2: ") {
+			t.Errorf("Line %q gave error %v", line, err)
+		}
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	dir := t.TempDir()
+	expectNoErr(t, os.WriteFile(filepath.Join(dir, "good.txt"), []byte("Hello World"), 0644))
+	expectNoErr(t, os.WriteFile(filepath.Join(dir, "bad.txt"), []byte("Goodbye"), 0644))
+	checksums := "" +
+		SHA256("Hello World") + "  good.txt\n" +
+		"MD5 (good.txt) = " + MD5("Hello World") + "\n" +
+		SHA256("Hello World") + "  bad.txt\n" +
+		SHA256("Hello World") + "  missing.txt\n"
+	expectNoErr(t, os.WriteFile(filepath.Join(dir, "SHA256SUMS"), []byte(checksums), 0644))
+
+	results, err := VerifyChecksums(filepath.Join(dir, "SHA256SUMS"))
+	expect(t, 4, len(results), "number of results")
+	expect(t, nil, results[0].Err, "good.txt SHA-256 error")
+	expect(t, nil, results[1].Err, "good.txt MD5 error")
+	expect(t, ErrChecksumMismatch, results[2].Err, "bad.txt error")
+	expect(t, SHA256("Goodbye"), results[2].Actual.Hex(), "bad.txt actual checksum")
+	expect(t, true, errors.Is(results[3].Err, os.ErrNotExist), "missing.txt is ErrNotExist")
+
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("Expected a *ChecksumError, got %v", err)
+	}
+	expect(t, 2, len(checksumErr.Failed), "number of failures")
+	if !strings.HasPrefix(err.Error(), "2 of 4 files failed verification: bad.txt: checksum mismatch; missing.txt: ") {
+		t.Errorf("Unexpected error message %q", err)
+	}
+
+	expectNoErr(t, os.WriteFile(filepath.Join(dir, "GOOD"), []byte(SHA256("Hello World")+"  good.txt\n"), 0644))
+	_, err = VerifyChecksums(filepath.Join(dir, "GOOD"))
+	expect(t, nil, err, "error verifying good checksums")
+}
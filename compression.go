@@ -0,0 +1,201 @@
+package jgh
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnsupportedEncodingError is returned when a request asks to be
+// compressed with, or a response arrives encoded in, a Content-Encoding
+// this package does not understand
+type UnsupportedEncodingError struct {
+	Encoding string
+}
+
+func (e UnsupportedEncodingError) Error() string {
+	return "unsupported Content-Encoding: " + strconv.Quote(e.Encoding)
+}
+
+// CompressionMiddleware compresses request bodies with encoding ("gzip" or
+// "deflate") and sets the Content-Encoding header. Requests without a body,
+// or that already have a Content-Encoding, are sent unmodified. Only use
+// this with servers that are known to accept compressed requests.
+func CompressionMiddleware(encoding string) Middleware {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Content-Encoding") != "" {
+				return next.RoundTrip(req)
+			}
+			body, err := RequestBody(req)
+			if err != nil {
+				return nil, err
+			}
+			if len(body) == 0 {
+				return next.RoundTrip(req)
+			}
+
+			compressed, err := compress(encoding, body)
+			if err != nil {
+				return nil, err
+			}
+			Logger.Printf("Request body compressed with %s from %d to %d bytes", encoding, len(body), len(compressed))
+
+			req = req.Clone(req.Context())
+			req.Header.Set("Content-Encoding", encoding)
+			req.ContentLength = int64(len(compressed))
+			if req.Header.Get("Content-Length") != "" {
+				req.Header.Set("Content-Length", strconv.Itoa(len(compressed)))
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+func compress(encoding string, data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip", "x-gzip":
+		w = gzip.NewWriter(&b)
+	case "deflate":
+		// "deflate" in HTTP means the zlib format (RFC 1950)
+		w = zlib.NewWriter(&b)
+	default:
+		return nil, UnsupportedEncodingError{encoding}
+	}
+	_, err := w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// DecompressionMiddleware transparently decodes gzip and deflate response
+// bodies. Go's transport only does this when it added the Accept-Encoding
+// header itself; this middleware also handles responses to requests where
+// the caller set Accept-Encoding. The Content-Encoding and Content-Length
+// headers are removed from decoded responses. A response in any other
+// encoding fails with an UnsupportedEncodingError.
+func DecompressionMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+
+		encodings := contentEncodings(resp.Header)
+		if len(encodings) == 0 || resp.Body == nil || resp.Body == http.NoBody {
+			return resp, nil
+		}
+
+		compressedBody := &countingBody{ReadCloser: resp.Body, done: func(int64) {}}
+		var reader io.Reader = compressedBody
+		// encodings are listed in the order they were applied
+		for i := len(encodings) - 1; i >= 0; i-- {
+			reader, err = decompressor(encodings[i], reader)
+			if err != nil {
+				resp.Body.Close() // nolint: errcheck
+				return nil, err
+			}
+		}
+
+		encoding := strings.Join(encodings, ", ")
+		resp.Body = &countingBody{
+			ReadCloser: readCloser{reader, compressedBody},
+			done: func(n int64) {
+				Logger.Printf("Response body decompressed with %s from %d to %d bytes", encoding, compressedBody.n, n)
+			},
+		}
+		resp.Header = resp.Header.Clone()
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		return resp, nil
+	})
+}
+
+// contentEncodings lists the non-identity encodings in a Content-Encoding
+// header, lowercased
+func contentEncodings(header http.Header) []string {
+	var encodings []string
+	for _, value := range header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	return encodings
+}
+
+// decompressor wraps r in a reader that decodes encoding. Readers are
+// created lazily, so a malformed body is reported when it is read rather
+// than when the response arrives.
+func decompressor(encoding string, r io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return &lazyReader{open: func() (io.Reader, error) {
+			return gzip.NewReader(r)
+		}}, nil
+	case "deflate":
+		return &lazyReader{open: func() (io.Reader, error) {
+			// deflate should be zlib wrapped, but some servers send raw
+			// deflate data, so check for a zlib header first
+			buffered := bufio.NewReader(r)
+			header, err := buffered.Peek(2)
+			if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+				return zlib.NewReader(buffered)
+			}
+			return flate.NewReader(buffered), nil
+		}}, nil
+	}
+	return nil, UnsupportedEncodingError{encoding}
+}
+
+// lazyReader calls open on the first Read
+type lazyReader struct {
+	open   func() (io.Reader, error)
+	once   sync.Once
+	reader io.Reader
+	err    error
+}
+
+func (r *lazyReader) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		r.reader, r.err = r.open()
+		if r.err != nil {
+			r.err = fmt.Errorf("failed to decompress response body: %w", r.err)
+		}
+	})
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(p)
+}
+
+// readCloser combines a Reader with the Closer of the underlying body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,89 @@
+package jgh
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect(t, "gzip", r.Header.Get("Content-Encoding"), "request Content-Encoding")
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Write([]byte(ReadAll(reader))) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	client := ClientWithMiddleware(nil, CompressionMiddleware("gzip"))
+	resp, status := HTTPRequest(client, "POST", server.URL, "", "", nil, "hello hello hello")
+	if status != 200 {
+		t.Errorf("Status is %d, not 200", status)
+	}
+	expect(t, "hello hello hello", resp, "echoed body")
+
+	client = ClientWithMiddleware(nil, CompressionMiddleware("br"))
+	success, msg := Try(0, 1, false, "", func() bool {
+		HTTPRequest(client, "POST", server.URL, "", "", nil, "hello")
+		return true
+	})
+	if success {
+		t.Error("Unsupported request encoding did not fail")
+	}
+	t.Log(msg)
+}
+
+func TestDecompressionMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		switch r.URL.Path {
+		case "/gzip":
+			gz := gzip.NewWriter(&b)
+			gz.Write([]byte("gzipped")) // nolint: errcheck
+			gz.Close()                  // nolint: errcheck
+		case "/deflate":
+			// raw deflate, without the zlib wrapper
+			fl, _ := flate.NewWriter(&b, flate.DefaultCompression)
+			fl.Write([]byte("deflated")) // nolint: errcheck
+			fl.Close()                   // nolint: errcheck
+		case "/deflate-zlib":
+			zl, err := compress("deflate", []byte("zlib deflated"))
+			if err != nil {
+				t.Error(err)
+			}
+			b.Write(zl)
+		case "/br":
+			b.WriteString("not really brotli")
+		}
+		encoding := r.URL.Path[1:]
+		if encoding == "deflate-zlib" {
+			encoding = "deflate"
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(b.Bytes()) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	// setting Accept-Encoding ourselves turns off Go's automatic gzip support
+	headers := map[string]string{"Accept-Encoding": "gzip, deflate"}
+	resp, _ := HTTPRequest(nil, "GET", server.URL+"/gzip", "", "", headers, "")
+	expect(t, "gzipped", resp, "gzip response")
+	resp, _ = HTTPRequest(nil, "GET", server.URL+"/deflate", "", "", headers, "")
+	expect(t, "deflated", resp, "raw deflate response")
+	resp, _ = HTTPRequest(nil, "GET", server.URL+"/deflate-zlib", "", "", headers, "")
+	expect(t, "zlib deflated", resp, "zlib deflate response")
+
+	success, _ := Try(0, 1, false, "", func() bool {
+		HTTPRequest(nil, "GET", server.URL+"/br", "", "", headers, "")
+		return true
+	})
+	if success {
+		t.Error("Unsupported response encoding did not fail")
+	}
+}
@@ -0,0 +1,362 @@
+package jgh
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Diff compares expected and got the same way reflect.DeepEqual does, but
+// instead of a bool it returns a description of every difference, one per
+// line, starting with the path to the field, map key or slice index that
+// differs. The paths start with name. An empty result means the values
+// are equal. opts relax the comparison (see CompareOption).
+func Diff(name string, expected interface{}, got interface{}, opts ...CompareOption) []string {
+	d := newDiffer(name, opts)
+	d.diff(name, reflect.ValueOf(expected), reflect.ValueOf(got))
+	return d.diffs
+}
+
+// CompareOption changes how Diff, Expect and CheckExpect decide that two
+// values are equal
+type CompareOption func(options *compareOptions)
+
+type compareOptions struct {
+	floatTolerance  float64
+	ignore          []*regexp.Regexp
+	nilEqualsEmpty  bool
+	unorderedSlices bool
+	equal           map[reflect.Type]func(a reflect.Value, b reflect.Value) bool
+}
+
+// FloatTolerance treats floats as equal if they differ by no more than
+// tolerance
+func FloatTolerance(tolerance float64) CompareOption {
+	return func(options *compareOptions) {
+		options.floatTolerance = tolerance
+	}
+}
+
+// IgnorePath skips the field, map key or slice index at path, which is
+// written the way Diff prints it but without the leading name, for
+// example "Address.Geo" or "Items[3].ID". [*] matches any index or key,
+// as in "Items[*].ID".
+func IgnorePath(path string) CompareOption {
+	pattern := regexp.QuoteMeta(strings.TrimPrefix(path, "."))
+	pattern = strings.ReplaceAll(pattern, `\[\*\]`, `\[[^\]]*\]`)
+	re := regexp.MustCompile("^" + pattern + "$")
+	return func(options *compareOptions) {
+		options.ignore = append(options.ignore, re)
+	}
+}
+
+// NilEqualsEmpty treats nil slices and maps as equal to empty ones
+func NilEqualsEmpty() CompareOption {
+	return func(options *compareOptions) {
+		options.nilEqualsEmpty = true
+	}
+}
+
+// UnorderedSlices compares slices and arrays as multisets, so the same
+// elements in a different order are equal
+func UnorderedSlices() CompareOption {
+	return func(options *compareOptions) {
+		options.unorderedSlices = true
+	}
+}
+
+// EqualFunc uses equal to compare values of type T, instead of comparing
+// them field by field. Values in unexported fields are still compared
+// field by field, since reflection can't hand them to equal.
+func EqualFunc[T any](equal func(a T, b T) bool) CompareOption {
+	return func(options *compareOptions) {
+		if options.equal == nil {
+			options.equal = make(map[reflect.Type]func(a reflect.Value, b reflect.Value) bool)
+		}
+		options.equal[reflect.TypeOf((*T)(nil)).Elem()] = func(a reflect.Value, b reflect.Value) bool {
+			// a nil interface value becomes T's zero value
+			av, _ := a.Interface().(T)
+			bv, _ := b.Interface().(T)
+			return equal(av, bv)
+		}
+	}
+}
+
+// TimeEqual compares time.Time values with time.Time.Equal, so the same
+// instant in different locations, or with and without a monotonic clock
+// reading, is equal
+func TimeEqual() CompareOption {
+	return EqualFunc(func(a time.Time, b time.Time) bool {
+		return a.Equal(b)
+	})
+}
+
+// visit is a pair of pointers that are being compared, used to stop
+// infinite recursion on cyclic data. Slices also need their lengths,
+// since slices of different lengths can share a pointer.
+type visit struct {
+	expected    uintptr
+	got         uintptr
+	typ         reflect.Type
+	expectedLen int
+	gotLen      int
+}
+
+type differ struct {
+	root    string
+	options compareOptions
+	diffs   []string
+	visited map[visit]bool
+}
+
+func newDiffer(root string, opts []CompareOption) *differ {
+	d := &differ{root: root, visited: make(map[visit]bool)}
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	return d
+}
+
+// equal reports whether expected and got (found at path) have no
+// differences, without recording anything
+func (d *differ) equal(path string, expected reflect.Value, got reflect.Value) bool {
+	sub := &differ{root: d.root, options: d.options, visited: make(map[visit]bool, len(d.visited))}
+	for v := range d.visited {
+		sub.visited[v] = true
+	}
+	sub.diff(path, expected, got)
+	return len(sub.diffs) == 0
+}
+
+func (d *differ) ignored(path string) bool {
+	if len(d.options.ignore) == 0 {
+		return false
+	}
+	relative := strings.TrimPrefix(strings.TrimPrefix(path, d.root), ".")
+	for _, re := range d.options.ignore {
+		if re.MatchString(relative) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) report(path string, format string, args ...interface{}) {
+	d.diffs = append(d.diffs, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (d *differ) diff(path string, expected reflect.Value, got reflect.Value) {
+	if d.ignored(path) {
+		return
+	}
+	if !expected.IsValid() || !got.IsValid() {
+		if expected.IsValid() != got.IsValid() {
+			d.report(path, "expected %s, got %s", formatDiffValue(expected), formatDiffValue(got))
+		}
+		return
+	}
+	if expected.Type() != got.Type() {
+		d.report(path, "expected type %s, got type %s", expected.Type(), got.Type())
+		return
+	}
+	if equal, ok := d.options.equal[expected.Type()]; ok && expected.CanInterface() && got.CanInterface() {
+		if !equal(expected, got) {
+			d.report(path, "expected %s, got %s", formatDiffValue(expected), formatDiffValue(got))
+		}
+		return
+	}
+	if d.options.nilEqualsEmpty {
+		switch expected.Kind() {
+		case reflect.Map, reflect.Slice:
+			if expected.Len() == 0 && got.Len() == 0 {
+				return
+			}
+		}
+	}
+
+	// remember pointers we have already compared, like reflect.DeepEqual
+	switch expected.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if !expected.IsNil() && !got.IsNil() {
+			v := visit{expected: expected.Pointer(), got: got.Pointer(), typ: expected.Type()}
+			if expected.Kind() == reflect.Slice {
+				v.expectedLen, v.gotLen = expected.Len(), got.Len()
+			}
+			if d.visited[v] {
+				return
+			}
+			d.visited[v] = true
+		}
+	}
+
+	switch expected.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if expected.IsNil() || got.IsNil() {
+			if expected.IsNil() != got.IsNil() {
+				d.report(path, "expected %s, got %s", formatDiffValue(expected), formatDiffValue(got))
+			}
+			return
+		}
+		d.diff(path, expected.Elem(), got.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < expected.NumField(); i++ {
+			d.diff(path+"."+expected.Type().Field(i).Name, expected.Field(i), got.Field(i))
+		}
+
+	case reflect.Map:
+		if expected.IsNil() != got.IsNil() {
+			d.report(path, "expected %s, got %s", formatDiffValue(expected), formatDiffValue(got))
+			return
+		}
+		for _, key := range unionKeys(expected, got) {
+			keyPath := path + "[" + formatDiffKey(key) + "]"
+			expectedValue := expected.MapIndex(key)
+			gotValue := got.MapIndex(key)
+			switch {
+			case !gotValue.IsValid():
+				d.report(keyPath, "missing, expected %s", formatDiffValue(expectedValue))
+			case !expectedValue.IsValid():
+				d.report(keyPath, "unexpected key, got %s", formatDiffValue(gotValue))
+			default:
+				d.diff(keyPath, expectedValue, gotValue)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if expected.Kind() == reflect.Slice && expected.IsNil() != got.IsNil() {
+			d.report(path, "expected %s, got %s", formatDiffValue(expected), formatDiffValue(got))
+			return
+		}
+		if d.options.unorderedSlices {
+			d.diffUnordered(path, expected, got)
+			return
+		}
+		if expected.Len() != got.Len() {
+			d.report(path, "expected length %d, got length %d", expected.Len(), got.Len())
+		}
+		for i := 0; i < expected.Len() || i < got.Len(); i++ {
+			indexPath := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= got.Len():
+				d.report(indexPath, "missing, expected %s", formatDiffValue(expected.Index(i)))
+			case i >= expected.Len():
+				d.report(indexPath, "unexpected element, got %s", formatDiffValue(got.Index(i)))
+			default:
+				d.diff(indexPath, expected.Index(i), got.Index(i))
+			}
+		}
+
+	case reflect.Float32, reflect.Float64:
+		difference := math.Abs(expected.Float() - got.Float())
+		if expected.Float() != got.Float() && !(difference <= d.options.floatTolerance) {
+			d.report(path, "expected %s, got %s", formatDiffValue(expected), formatDiffValue(got))
+		}
+
+	default:
+		if !leafEqual(expected, got) {
+			d.report(path, "expected %s, got %s", formatDiffValue(expected), formatDiffValue(got))
+		}
+	}
+}
+
+// diffUnordered pairs up equal elements of two slices regardless of their
+// position, and reports the ones left over
+func (d *differ) diffUnordered(path string, expected reflect.Value, got reflect.Value) {
+	used := make([]bool, got.Len())
+	for i := 0; i < expected.Len(); i++ {
+		found := false
+		for j := 0; j < got.Len(); j++ {
+			if !used[j] && d.equal(path+"[*]", expected.Index(i), got.Index(j)) {
+				used[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.report(path, "missing element %s", formatDiffValue(expected.Index(i)))
+		}
+	}
+	for j := 0; j < got.Len(); j++ {
+		if !used[j] {
+			d.report(path, "unexpected element %s", formatDiffValue(got.Index(j)))
+		}
+	}
+}
+
+// leafEqual compares values of the same type that have no elements, with
+// the same rules as reflect.DeepEqual
+func leafEqual(expected reflect.Value, got reflect.Value) bool {
+	switch expected.Kind() {
+	case reflect.Bool:
+		return expected.Bool() == got.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return expected.Int() == got.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return expected.Uint() == got.Uint()
+	case reflect.Float32, reflect.Float64:
+		return expected.Float() == got.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return expected.Complex() == got.Complex()
+	case reflect.String:
+		return expected.String() == got.String()
+	case reflect.Chan, reflect.UnsafePointer:
+		return expected.Pointer() == got.Pointer()
+	case reflect.Func:
+		// like reflect.DeepEqual, funcs are only equal if both are nil
+		return expected.IsNil() && got.IsNil()
+	}
+	return false
+}
+
+// unionKeys returns the keys of both maps, sorted by their formatted value
+// so the output is stable
+func unionKeys(a reflect.Value, b reflect.Value) []reflect.Value {
+	seen := make(map[interface{}]bool)
+	var keys []reflect.Value
+	for _, m := range []reflect.Value{a, b} {
+		for _, key := range m.MapKeys() {
+			// keys of unexported fields can't be turned into interfaces,
+			// so fall back to their formatted value for de-duplication
+			var id interface{} = formatDiffKey(key)
+			if key.CanInterface() {
+				id = key.Interface()
+			}
+			if !seen[id] {
+				seen[id] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return formatDiffKey(keys[i]) < formatDiffKey(keys[j])
+	})
+	return keys
+}
+
+func formatDiffKey(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return strconv.Quote(key.String())
+	}
+	return fmt.Sprintf("%v", key)
+}
+
+func formatDiffValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return "nil"
+		}
+	}
+	return fmt.Sprintf("%+v", v)
+}
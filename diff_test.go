@@ -0,0 +1,145 @@
+package jgh
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type diffTestUser struct {
+	Phone   string
+	Address struct {
+		Geo struct {
+			Lat string
+		}
+	}
+	Company struct {
+		Name string
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var user1, user2 diffTestUser
+	user1.Address.Geo.Lat = "-37.3159"
+	user2.Address.Geo.Lat = "99.9999"
+	user2.Phone = "555-1234"
+	expect(t, []string{
+		`user.Phone: expected "", got "555-1234"`,
+		`user.Address.Geo.Lat: expected "-37.3159", got "99.9999"`,
+	}, Diff("user", user1, user2), "diff of users")
+
+	expect(t, []string{
+		`m["b"]: expected 2, got 3`,
+		`m["c"]: missing, expected 3`,
+		`m["d"]: unexpected key, got 4`,
+	}, Diff("m", map[string]int{"a": 1, "b": 2, "c": 3}, map[string]int{"a": 1, "b": 3, "d": 4}), "diff of maps")
+
+	expect(t, []string{
+		"s: expected length 2, got length 3",
+		"s[1]: expected 2, got 5",
+		"s[2]: unexpected element, got 6",
+	}, Diff("s", []int{1, 2}, []int{1, 5, 6}), "diff of slices")
+
+	expect(t, []string{"s: expected nil, got []"}, Diff("s", []int(nil), []int{}), "diff of nil and empty slice")
+	expect(t, []string{"x: expected type int, got type string"}, Diff("x", 1, "1"), "diff of types")
+
+	type node struct {
+		Next *node
+		N    int
+	}
+	cycle := &node{N: 1}
+	cycle.Next = cycle
+	if len(Diff("cycle", cycle, cycle)) != 0 {
+		t.Error("Cyclic value differs from itself")
+	}
+	// slices sharing a backing array are still compared by length
+	type pair struct{ A, B []int }
+	s := []int{1, 2, 3}
+	expect(t, []string{
+		"p.B: expected length 2, got length 3",
+		"p.B[2]: unexpected element, got 3",
+	}, Diff("p", pair{s[:2], s[:2]}, pair{s[:2], s[:3]}), "diff of slices sharing an array")
+	if CheckExpect(pair{s[:2], s[:2]}, pair{s[:2], s[:3]}, "p", NilEqualsEmpty()) == nil {
+		t.Error("Slices sharing an array with different lengths are equal")
+	}
+}
+
+func TestExpectDiffMessage(t *testing.T) {
+	var user1, user2 diffTestUser
+	user2.Company.Name = "bazbuz"
+	_, msg := Try(0, 1, false, "", func() bool {
+		Expect(user1, user2, "user2")
+		return true
+	})
+	expected := "Expected user2 to be equal, differences:\n\t" + `user2.Company.Name: expected "", got "bazbuz"`
+	expect(t, expected, msg, "panic message")
+
+	_, msg = Try(0, 1, false, "", func() bool {
+		Expect(7, 8, "seven")
+		return true
+	})
+	expect(t, "Expected seven to be 7, got 8", msg, "panic message")
+
+	err := CheckExpect(user1, user2, "user2")
+	if !strings.Contains(err.Error(), "user2.Company.Name") {
+		t.Errorf("CheckExpect error does not include the diff: %v", err)
+	}
+}
+
+func TestCompareOptions(t *testing.T) {
+	// use variables so the sum isn't done with exact constant arithmetic
+	a, b := 0.1, 0.2
+	if CheckExpect(0.3, a+b, "sum") == nil {
+		t.Error("Floats compared equal without a tolerance")
+	}
+	expect(t, 0.3, a+b, "sum", FloatTolerance(1e-9))
+
+	type item struct {
+		ID   int
+		Name string
+	}
+	expected := []item{{1, "a"}, {2, "b"}}
+	got := []item{{20, "b"}, {10, "a"}}
+	expect(t, expected, got, "items", UnorderedSlices(), IgnorePath("[*].ID"))
+	if CheckExpect(expected, got, "items", UnorderedSlices()) == nil {
+		t.Error("Different IDs were ignored without IgnorePath")
+	}
+
+	var user1, user2 diffTestUser
+	user2.Address.Geo.Lat = "99.9999"
+	expect(t, user1, user2, "user", IgnorePath("Address.Geo"))
+
+	expect(t, map[string][]int{"a": nil}, map[string][]int{"a": {}}, "map", NilEqualsEmpty())
+	if CheckExpect([]int(nil), []int{}, "slice") == nil {
+		t.Error("nil and empty slices compared equal without NilEqualsEmpty")
+	}
+
+	now := time.Now()
+	utc := now.UTC() // strips the monotonic reading and changes the location
+	if CheckExpect(now, utc, "time") == nil {
+		t.Error("Times compared equal without TimeEqual")
+	}
+	expect(t, now, utc, "time", TimeEqual())
+
+	caseInsensitive := EqualFunc(func(a string, b string) bool {
+		return strings.EqualFold(a, b)
+	})
+	expect(t, []string{"Hello"}, []string{"HELLO"}, "words", caseInsensitive)
+
+	type result struct{ Err error }
+	sameMessage := EqualFunc(func(a error, b error) bool {
+		return a == nil && b == nil || a != nil && b != nil && a.Error() == b.Error()
+	})
+	expect(t, result{}, result{}, "nil errors", sameMessage)
+	expect(t, result{errors.New("EOF")}, result{io.EOF}, "errors", sameMessage)
+	if CheckExpect(result{}, result{io.EOF}, "result", sameMessage) == nil {
+		t.Error("nil and non-nil errors compared equal")
+	}
+
+	err := CheckExpect([]int{1, 2, 3}, []int{3, 4, 1}, "numbers", UnorderedSlices())
+	if err == nil || !strings.Contains(err.Error(), "numbers: missing element 2") || !strings.Contains(err.Error(), "numbers: unexpected element 4") {
+		t.Errorf("Unordered diff is wrong: %v", err)
+	}
+}
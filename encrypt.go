@@ -0,0 +1,286 @@
+package jgh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The functions in this file encrypt secrets at rest, such as API
+// credentials in a config file found with RelPath. Everything is
+// AES-256-GCM, which also detects any change to the ciphertext. The first
+// byte of each ciphertext says which of these formats follows it:
+//
+//	1: key ID (4 bytes) | nonce (12 bytes) | sealed data
+//	2: log2(N), r, p (1 byte each) | salt (16 bytes) | nonce (12 bytes) | sealed data
+//
+// Format 1 is made by a Keyring, format 2 by EncryptWithPassphrase. The
+// header before the nonce is authenticated along with the data.
+
+const (
+	encryptionFormatKey        = 1
+	encryptionFormatPassphrase = 2
+	keyHeaderSize              = 1 + 4
+	passphraseHeaderSize       = 1 + 3 + 16
+	gcmNonceSize               = 12
+)
+
+// KeySize is the size of the AES-256 keys used by Keyring
+const KeySize = 32
+
+// Errors returned when decrypting
+var (
+	// ErrDecrypt means the ciphertext was changed, or the key or
+	// passphrase is wrong
+	ErrDecrypt = errors.New("decryption failed")
+	// ErrUnknownKey means the ciphertext was encrypted with a key that is
+	// not in the Keyring
+	ErrUnknownKey = errors.New("ciphertext encrypted with an unknown key")
+	// ErrCiphertextFormat means the data is not something this package
+	// encrypted
+	ErrCiphertextFormat = errors.New("unsupported ciphertext format")
+)
+
+// GenerateKey returns a new random key for a Keyring
+func GenerateKey() []byte {
+	key := make([]byte, KeySize)
+	randomBytes(key)
+	return key
+}
+
+// KDFParams are the scrypt cost parameters for deriving a key from a
+// passphrase (see Scrypt)
+type KDFParams struct {
+	N int
+	R int
+	P int
+}
+
+// DefaultKDFParams use 32 MiB of memory and take a fraction of a second,
+// as recommended for interactive logins
+var DefaultKDFParams = KDFParams{N: 1 << 15, R: 8, P: 1}
+
+// DeriveKey derives a key for a Keyring from a passphrase and a salt,
+// which should be random and stored alongside whatever was encrypted
+func DeriveKey(passphrase string, salt []byte, params KDFParams) ([]byte, error) {
+	return Scrypt([]byte(passphrase), salt, params.N, params.R, params.P, KeySize)
+}
+
+// Keyring holds the keys for encrypting and decrypting secrets, by ID.
+// New data is always encrypted with the Primary key, and each ciphertext
+// records the ID of its key, so after a new key is added with Rotate, old
+// data can still be decrypted (and re-encrypted with Reencrypt) until the
+// old key is removed.
+type Keyring struct {
+	Primary uint32
+	Keys    map[uint32][]byte
+}
+
+// NewKeyring returns a Keyring with key as its primary key, with ID 1
+func NewKeyring(key []byte) *Keyring {
+	return &Keyring{Primary: 1, Keys: map[uint32][]byte{1: key}}
+}
+
+// Rotate adds key to the keyring with the next free ID and makes it the
+// primary key. It returns the new ID.
+func (k *Keyring) Rotate(key []byte) uint32 {
+	if k.Keys == nil {
+		k.Keys = make(map[uint32][]byte)
+	}
+	var id uint32
+	for existing := range k.Keys {
+		if existing > id {
+			id = existing
+		}
+	}
+	id++
+	k.Keys[id] = key
+	k.Primary = id
+	return id
+}
+
+// Encrypt encrypts plaintext with the primary key
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	key, found := k.Keys[k.Primary]
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	header := make([]byte, keyHeaderSize)
+	header[0] = encryptionFormatKey
+	binary.BigEndian.PutUint32(header[1:], k.Primary)
+	return seal(key, header, plaintext)
+}
+
+// Decrypt decrypts ciphertext from Encrypt, with whichever key it was
+// encrypted with
+func (k *Keyring) Decrypt(ciphertext []byte) ([]byte, error) {
+	id, err := k.KeyID(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	key, found := k.Keys[id]
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	return open(key, ciphertext[:keyHeaderSize], ciphertext[keyHeaderSize:])
+}
+
+// KeyID returns the ID of the key ciphertext was encrypted with, so you
+// can tell whether it needs to be re-encrypted after a rotation
+func (k *Keyring) KeyID(ciphertext []byte) (uint32, error) {
+	if len(ciphertext) < keyHeaderSize || ciphertext[0] != encryptionFormatKey {
+		return 0, ErrCiphertextFormat
+	}
+	return binary.BigEndian.Uint32(ciphertext[1:]), nil
+}
+
+// Reencrypt decrypts ciphertext and encrypts it again with the primary
+// key
+func (k *Keyring) Reencrypt(ciphertext []byte) ([]byte, error) {
+	plaintext, err := k.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt(plaintext)
+}
+
+// EncryptString is Encrypt for text, returning URL safe base 64 that can
+// be stored in a config file
+func (k *Keyring) EncryptString(plaintext string) (string, error) {
+	ciphertext, err := k.Encrypt([]byte(plaintext))
+	return base64.RawURLEncoding.EncodeToString(ciphertext), err
+}
+
+// DecryptString decrypts text from EncryptString
+func (k *Keyring) DecryptString(ciphertext string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", ErrCiphertextFormat
+	}
+	plaintext, err := k.Decrypt(decoded)
+	return string(plaintext), err
+}
+
+// WriteFile encrypts plaintext and writes it to filename, which only the
+// current user may read
+func (k *Keyring) WriteFile(filename string, plaintext []byte) error {
+	ciphertext, err := k.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, ciphertext, 0600)
+}
+
+// ReadFile reads and decrypts a file from WriteFile
+func (k *Keyring) ReadFile(filename string) ([]byte, error) {
+	ciphertext, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := k.Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return plaintext, nil
+}
+
+// EncryptWithPassphrase encrypts plaintext with a key derived from
+// passphrase with params. The salt and params are stored in the
+// ciphertext, so only the passphrase is needed to decrypt it.
+func EncryptWithPassphrase(passphrase string, plaintext []byte, params KDFParams) ([]byte, error) {
+	logN := 0
+	for logN < 62 && 1<<logN < params.N {
+		logN++
+	}
+	if 1<<logN != params.N || !passphraseParamsOK(logN, params.R, params.P) {
+		return nil, fmt.Errorf("KDF parameters must have N a power of 2, 128 * N * R up to %d MiB and P up to %d", maxPassphraseMemory>>20, maxPassphraseP)
+	}
+	header := make([]byte, passphraseHeaderSize)
+	header[0] = encryptionFormatPassphrase
+	header[1], header[2], header[3] = byte(logN), byte(params.R), byte(params.P)
+	randomBytes(header[4:])
+
+	key, err := DeriveKey(passphrase, header[4:], params)
+	if err != nil {
+		return nil, err
+	}
+	return seal(key, header, plaintext)
+}
+
+// Limits on the KDF parameters of passphrase encryption, so that an
+// untrusted ciphertext can't make DecryptWithPassphrase use too much
+// memory (128 * r * 2^logN bytes) or time (p times that). EncryptWithPassphrase
+// applies the same limits, so everything it encrypts can be decrypted.
+const (
+	maxPassphraseMemory = 256 << 20
+	maxPassphraseP      = 4
+)
+
+// passphraseParamsOK reports whether N = 2^logN, r and p are within the
+// limits and r and p fit in the ciphertext header
+func passphraseParamsOK(logN int, r int, p int) bool {
+	return logN <= 30 && r >= 1 && r <= 255 && p >= 1 && p <= maxPassphraseP && 128*uint64(r)<<logN <= maxPassphraseMemory
+}
+
+// DecryptWithPassphrase decrypts ciphertext from EncryptWithPassphrase
+func DecryptWithPassphrase(passphrase string, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < passphraseHeaderSize || ciphertext[0] != encryptionFormatPassphrase {
+		return nil, ErrCiphertextFormat
+	}
+	header := ciphertext[:passphraseHeaderSize]
+	if !passphraseParamsOK(int(header[1]), int(header[2]), int(header[3])) {
+		return nil, ErrCiphertextFormat
+	}
+	params := KDFParams{N: 1 << header[1], R: int(header[2]), P: int(header[3])}
+	key, err := DeriveKey(passphrase, header[4:], params)
+	if err != nil {
+		return nil, ErrCiphertextFormat
+	}
+	return open(key, header, ciphertext[passphraseHeaderSize:])
+}
+
+// seal encrypts plaintext with key, returning header, a random nonce and
+// the sealed data
+func seal(key []byte, header []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(header)+gcmNonceSize, len(header)+gcmNonceSize+len(plaintext)+gcm.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	randomBytes(nonce)
+	return gcm.Seal(out, nonce, plaintext, header), nil
+}
+
+// open decrypts the nonce and sealed data in body, checking that header
+// is the one it was sealed with
+func open(key []byte, header []byte, body []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < gcmNonceSize+gcm.Overhead() {
+		return nil, ErrCiphertextFormat
+	}
+	plaintext, err := gcm.Open(nil, body[:gcmNonceSize], body[gcmNonceSize:], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, not %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
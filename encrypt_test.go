@@ -0,0 +1,176 @@
+package jgh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testKDFParams are cheap, so the tests run quickly
+var testKDFParams = KDFParams{N: 1 << 10, R: 8, P: 1}
+
+func TestKeyring(t *testing.T) {
+	keyring := NewKeyring(GenerateKey())
+	ciphertext, err := keyring.Encrypt([]byte("api key"))
+	expectNoErr(t, err)
+	plaintext, err := keyring.Decrypt(ciphertext)
+	expectNoErr(t, err)
+	expect(t, "api key", string(plaintext), "decrypted text")
+
+	again, err := keyring.Encrypt([]byte("api key"))
+	expectNoErr(t, err)
+	if string(again) == string(ciphertext) {
+		t.Error("Encrypting twice gave the same ciphertext")
+	}
+
+	// any change to the ciphertext, including the header, is detected
+	for i := range ciphertext {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[i] ^= 1
+		if _, err := keyring.Decrypt(tampered); err == nil {
+			t.Fatalf("Changing byte %d wasn't detected", i)
+		}
+	}
+	_, err = NewKeyring(GenerateKey()).Decrypt(ciphertext)
+	expect(t, ErrDecrypt, err, "error with the wrong key")
+	_, err = keyring.Decrypt([]byte("nope"))
+	expect(t, ErrCiphertextFormat, err, "error for garbage")
+}
+
+func TestKeyringRotation(t *testing.T) {
+	keyring := NewKeyring(GenerateKey())
+	old, err := keyring.Encrypt([]byte("secret"))
+	expectNoErr(t, err)
+
+	expect(t, uint32(2), keyring.Rotate(GenerateKey()), "new key ID")
+	plaintext, err := keyring.Decrypt(old)
+	expectNoErr(t, err)
+	expect(t, "secret", string(plaintext), "old ciphertext after rotation")
+
+	rotated, err := keyring.Reencrypt(old)
+	expectNoErr(t, err)
+	id, err := keyring.KeyID(rotated)
+	expectNoErr(t, err)
+	expect(t, uint32(2), id, "key ID after re-encrypting")
+
+	delete(keyring.Keys, 1)
+	_, err = keyring.Decrypt(old)
+	expect(t, ErrUnknownKey, err, "error after removing the old key")
+	plaintext, err = keyring.Decrypt(rotated)
+	expectNoErr(t, err)
+	expect(t, "secret", string(plaintext), "re-encrypted text")
+}
+
+func TestKeyringStringsAndFiles(t *testing.T) {
+	keyring := NewKeyring(GenerateKey())
+	encrypted, err := keyring.EncryptString("hunter2")
+	expectNoErr(t, err)
+	decrypted, err := keyring.DecryptString(encrypted)
+	expectNoErr(t, err)
+	expect(t, "hunter2", decrypted, "decrypted string")
+	_, err = keyring.DecryptString("not base64!")
+	expect(t, ErrCiphertextFormat, err, "error for invalid base64")
+
+	filename := filepath.Join(t.TempDir(), "credentials.enc")
+	expectNoErr(t, keyring.WriteFile(filename, []byte(`{"token":"abc"}`)))
+	info, err := os.Stat(filename)
+	expectNoErr(t, err)
+	expect(t, os.FileMode(0600), info.Mode().Perm(), "file permissions")
+	contents, err := keyring.ReadFile(filename)
+	expectNoErr(t, err)
+	expect(t, `{"token":"abc"}`, string(contents), "file contents")
+
+	_, err = NewKeyring(GenerateKey()).ReadFile(filename)
+	expect(t, true, errors.Is(err, ErrDecrypt), "reading with the wrong key is ErrDecrypt")
+}
+
+func TestPassphraseEncryption(t *testing.T) {
+	ciphertext, err := EncryptWithPassphrase("correct horse", []byte("secret"), testKDFParams)
+	expectNoErr(t, err)
+	plaintext, err := DecryptWithPassphrase("correct horse", ciphertext)
+	expectNoErr(t, err)
+	expect(t, "secret", string(plaintext), "decrypted text")
+
+	_, err = DecryptWithPassphrase("battery staple", ciphertext)
+	expect(t, ErrDecrypt, err, "error with the wrong passphrase")
+
+	// the KDF parameters are authenticated too, and absurd ones rejected
+	tampered := append([]byte(nil), ciphertext...)
+	tampered[1]++
+	_, err = DecryptWithPassphrase("correct horse", tampered)
+	expect(t, ErrDecrypt, err, "error with changed parameters")
+	tampered[1] = 40
+	_, err = DecryptWithPassphrase("correct horse", tampered)
+	expect(t, ErrCiphertextFormat, err, "error with a huge N")
+
+	_, err = EncryptWithPassphrase("x", nil, KDFParams{N: 1000, R: 8, P: 1})
+	if err == nil {
+		t.Error("N that isn't a power of 2 was accepted")
+	}
+
+	keyring := NewKeyring(GenerateKey())
+	fromKeyring, err := keyring.Encrypt([]byte("secret"))
+	expectNoErr(t, err)
+	_, err = DecryptWithPassphrase("correct horse", fromKeyring)
+	expect(t, ErrCiphertextFormat, err, "error for a keyring ciphertext")
+	_, err = keyring.Decrypt(ciphertext)
+	expect(t, ErrCiphertextFormat, err, "error for a passphrase ciphertext")
+}
+
+func TestDeriveKey(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key, err := DeriveKey("passphrase", salt, testKDFParams)
+	expectNoErr(t, err)
+	expect(t, KeySize, len(key), "key length")
+	same, err := DeriveKey("passphrase", salt, testKDFParams)
+	expectNoErr(t, err)
+	expect(t, key, same, "key derived again")
+
+	ciphertext, err := NewKeyring(key).Encrypt([]byte("data"))
+	expectNoErr(t, err)
+	plaintext, err := NewKeyring(same).Decrypt(ciphertext)
+	expectNoErr(t, err)
+	expect(t, "data", string(plaintext), "decrypted with the derived key")
+}
+
+func TestPassphraseKDFLimits(t *testing.T) {
+	// the largest R and P that can be stored can also be decrypted
+	params := KDFParams{N: 2, R: 255, P: maxPassphraseP}
+	ciphertext, err := EncryptWithPassphrase("pw", []byte("data"), params)
+	if err != nil {
+		t.Fatalf("Encrypting with %+v failed: %s", params, err)
+	}
+	plaintext, err := DecryptWithPassphrase("pw", ciphertext)
+	if err != nil || string(plaintext) != "data" {
+		t.Errorf("Decrypting with %+v gave %q, %v", params, plaintext, err)
+	}
+
+	// parameters that use too much memory or time are refused when encrypting...
+	for _, params := range []KDFParams{
+		{N: maxPassphraseMemory / 128, R: 2, P: 1},
+		{N: 2 * maxPassphraseMemory / 128, R: 1, P: 1},
+		{N: 2, R: 256, P: 1},
+		{N: 2, R: 1, P: maxPassphraseP + 1},
+		{N: 2, R: 0, P: 1},
+		{N: 2, R: 1, P: 0},
+	} {
+		if _, err := EncryptWithPassphrase("pw", []byte("data"), params); err == nil {
+			t.Errorf("Encrypting with %+v was accepted", params)
+		}
+	}
+
+	// ...and crafted headers are rejected when decrypting, before running the KDF
+	for _, header := range [][3]byte{{22, 32, 16}, {19, 16, 16}, {21, 2, 1}, {1, 1, maxPassphraseP + 1}, {255, 1, 1}} {
+		tampered := append([]byte(nil), ciphertext...)
+		copy(tampered[1:], header[:])
+		start := time.Now()
+		if _, err := DecryptWithPassphrase("pw", tampered); err != ErrCiphertextFormat {
+			t.Errorf("Header %v gave %v, expected ErrCiphertextFormat", header, err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("Header %v took %s to reject", header, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package jgh
+
+import "testing"
+
+// expect reports a test failure if got is not equal to expected, like Expect
+// but without panicking, so the remaining checks still run
+func expect(t testing.TB, expected interface{}, got interface{}, name string, opts ...CompareOption) bool {
+	t.Helper()
+	if err := compare(expected, got, name, opts); err != nil {
+		t.Error(err)
+		return false
+	}
+	return true
+}
+
+// expectNoErr stops the test if err is not nil
+func expectNoErr(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
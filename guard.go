@@ -0,0 +1,101 @@
+package jgh
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"runtime/debug"
+)
+
+// Catch runs f and returns a *PanicError if it panics, or nil if it
+// doesn't. Since most of this package reports errors by panicking, this is
+// the easiest way to use it from code that must not crash, like a
+// goroutine in a long-running service. Panics from PanicOnErr unwrap to
+// the original error.
+func Catch(f func()) error {
+	return Guard(func() error {
+		f()
+		return nil
+	})
+}
+
+// Guard runs f and returns its error, or a *PanicError if it panics
+func Guard(f func() error) (err error) {
+	defer func() {
+		if panicMsg := recover(); panicMsg != nil {
+			err = &PanicError{Value: panicMsg, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// GuardHandler wraps handler so that a panic while serving a request is
+// logged (with its stack trace) to Logger and answered with a 500 Internal
+// Server Error, rather than being left to net/http. If the response was
+// already started, it is aborted with http.ErrAbortHandler instead, which
+// is also re-panicked as is, since it is used to deliberately abort a
+// response.
+func GuardHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracked := &headerTrackingWriter{ResponseWriter: w}
+		err := Catch(func() {
+			handler.ServeHTTP(tracked, r)
+		})
+		if err == nil {
+			return
+		}
+
+		panicErr := err.(*PanicError)
+		if panicErr.Value == http.ErrAbortHandler {
+			panic(http.ErrAbortHandler)
+		}
+		Logger.Printf("Panic while serving %s %s: %+v", r.Method, r.URL, panicErr)
+
+		// if the handler already started its response, it's too late to
+		// change the status code, so abort the response instead of letting
+		// a truncated body look complete
+		if tracked.wroteHeader {
+			panic(http.ErrAbortHandler)
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	})
+}
+
+// headerTrackingWriter remembers whether the response has been started
+type headerTrackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *headerTrackingWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerTrackingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, so streaming handlers still work when
+// guarded. It does nothing if the original writer can't flush.
+func (w *headerTrackingWriter) Flush() {
+	w.wroteHeader = true
+	http.NewResponseController(w.ResponseWriter).Flush() // nolint: errcheck
+}
+
+// Hijack implements http.Hijacker. It returns an error wrapping
+// http.ErrNotSupported if the original writer can't be hijacked.
+func (w *headerTrackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		// the connection belongs to the handler now, so we can't answer
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the original writer
+func (w *headerTrackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
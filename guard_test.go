@@ -0,0 +1,97 @@
+package jgh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCatch(t *testing.T) {
+	if Catch(func() {}) != nil {
+		t.Error("Catch returned an error for a function that did not panic")
+	}
+
+	err := Catch(func() {
+		PanicOnErr(io.EOF)
+	})
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Panic from PanicOnErr did not unwrap to the original error: %v", err)
+	}
+
+	err = Catch(func() {
+		Expect(1, 2, "one")
+	})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || !strings.HasPrefix(panicErr.Value.(string), "Expected one to be") {
+		t.Errorf("Panic from Expect was not caught: %v", err)
+	}
+	if !strings.Contains(fmt.Sprintf("%+v", err), "TestCatch") {
+		t.Error("Detailed format does not include the stack trace")
+	}
+
+	expect(t, io.ErrClosedPipe, Guard(func() error { return io.ErrClosedPipe }), "Guard error")
+}
+
+func TestGuardHandler(t *testing.T) {
+	server := httptest.NewServer(GuardHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("AAAAAH!")
+		}
+		w.Write([]byte("fine")) // nolint: errcheck
+	})))
+	defer server.Close()
+
+	resp, status := HTTPRequest(nil, "GET", server.URL+"/panic", "", "", nil, "")
+	expect(t, 500, status, "status after panic")
+	expect(t, "Internal Server Error\n", resp, "response after panic")
+
+	resp, status = HTTPRequest(nil, "GET", server.URL+"/", "", "", nil, "")
+	expect(t, 200, status, "status")
+	expect(t, "fine", resp, "response")
+}
+
+func TestGuardHandlerStreaming(t *testing.T) {
+	flushed := make(chan bool, 1)
+	server := httptest.NewServer(GuardHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			flushed <- false
+			return
+		}
+		fmt.Fprint(w, "event: one\n\n")
+		flusher.Flush()
+		flushed <- true
+		panic("stream broke")
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if !<-flushed {
+		t.Fatal("Guarded writer is not an http.Flusher")
+	}
+	// the flush started the response, so the panic can't turn it into a
+	// 500, but it must abort the response rather than end it cleanly
+	if resp.StatusCode != 200 {
+		t.Errorf("Status is %d after flushing, expected 200", resp.StatusCode)
+	}
+	if body, err := io.ReadAll(resp.Body); err == nil {
+		t.Errorf("Reading the aborted response gave %q without an error", body)
+	}
+
+	_, isHijacker := http.ResponseWriter(&headerTrackingWriter{ResponseWriter: httptest.NewRecorder()}).(http.Hijacker)
+	if !isHijacker {
+		t.Error("Guarded writer is not an http.Hijacker")
+	}
+	_, _, err = (&headerTrackingWriter{ResponseWriter: httptest.NewRecorder()}).Hijack()
+	if !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Hijacking a recorder gave %v, expected http.ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,342 @@
+package jgh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HARRecorder records HTTP exchanges in the HAR 1.2 format
+// (http://www.softwareishard.com/blog/har-12-spec/), which can be opened in
+// the network tab of browser devtools. Add its Middleware to the end of
+// Middlewares so it sees requests exactly as they are sent, and Close it
+// to write the file:
+//
+//	recorder := NewHARRecorder("debug.har")
+//	defer recorder.Close()
+//	Middlewares = append(Middlewares, recorder.Middleware)
+//
+// Compressed response bodies are decoded for the log, since the recorder
+// usually sits below DecompressionMiddleware.
+type HARRecorder struct {
+	// Filename, if set, is where Close saves the log
+	Filename string
+
+	mutex   sync.Mutex
+	entries []HAREntry
+}
+
+// NewHARRecorder makes a recorder that saves to filename when it is
+// closed. filename may be empty to only keep entries in memory.
+func NewHARRecorder(filename string) *HARRecorder {
+	return &HARRecorder{Filename: filename}
+}
+
+// HAR is the top level object in a HAR file
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the log object in a HAR file
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the program that made a HAR file
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request/response exchange
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest describes the request half of an exchange
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse describes the response half of an exchange
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is used for headers, cookies and query parameters
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the body of a request
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is the body of a response. Bodies that are not valid UTF-8
+// are base64 encoded, as indicated by Encoding.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings breaks down the time of an exchange in milliseconds. -1
+// means the timing is not available.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Middleware records every exchange that passes through it. Requests that
+// fail without a response are recorded with a status of 0 and the error
+// as a comment.
+func (r *HARRecorder) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		reqBody, err := RequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		wait := time.Since(start)
+
+		entry := HAREntry{
+			StartedDateTime: start.Format(time.RFC3339Nano),
+			Request:         harRequest(req, reqBody),
+			Timings: HARTimings{
+				Blocked: -1,
+				DNS:     -1,
+				Connect: -1,
+				Wait:    milliseconds(wait),
+				SSL:     -1,
+			},
+		}
+
+		if err != nil {
+			entry.Time = milliseconds(wait)
+			entry.Comment = err.Error()
+			entry.Response = HARResponse{
+				Cookies:     []HARNameValue{},
+				Headers:     []HARNameValue{},
+				HeadersSize: -1,
+				BodySize:    -1,
+			}
+			r.add(entry)
+			return resp, err
+		}
+
+		respBody, readErr := ResponseBody(resp)
+		receive := time.Since(start) - wait
+		entry.Timings.Receive = milliseconds(receive)
+		entry.Time = milliseconds(wait + receive)
+		entry.Response = harResponse(resp, respBody)
+		if readErr != nil {
+			entry.Comment = readErr.Error()
+		}
+		r.add(entry)
+
+		return resp, readErr
+	})
+}
+
+// Entries returns a copy of every exchange recorded so far
+func (r *HARRecorder) Entries() []HAREntry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]HAREntry(nil), r.entries...)
+}
+
+// WriteTo writes the recorded exchanges to w as a HAR file
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writeTo(w)
+}
+
+// Save writes the recorded exchanges to filename as a HAR file
+func (r *HARRecorder) Save(filename string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.save(filename)
+}
+
+// Close saves the recorded exchanges to Filename, if it is set
+func (r *HARRecorder) Close() error {
+	if r.Filename == "" {
+		return nil
+	}
+	return r.Save(r.Filename)
+}
+
+func (r *HARRecorder) add(entry HAREntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// save must be called with the mutex held
+func (r *HARRecorder) save(filename string) error {
+	var b strings.Builder
+	_, err := r.writeTo(&b)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, []byte(b.String()), 0644)
+}
+
+// writeTo must be called with the mutex held
+func (r *HARRecorder) writeTo(w io.Writer) (int64, error) {
+	har := HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "jgh", Version: "1.1"},
+		Entries: r.entries,
+	}}
+	if har.Log.Entries == nil {
+		har.Log.Entries = []HAREntry{}
+	}
+	bytes, err := json.MarshalIndent(har, "", "\t")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(bytes)
+	return int64(n), err
+}
+
+func harRequest(req *http.Request, body []byte) HARRequest {
+	harReq := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		harReq.Headers = append(harReq.Headers, HARNameValue{Name: "Host", Value: req.Host})
+	}
+	for _, cookie := range req.Cookies() {
+		harReq.Cookies = append(harReq.Cookies, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	query := req.URL.Query()
+	for _, name := range sortedKeys(query) {
+		for _, value := range query[name] {
+			harReq.QueryString = append(harReq.QueryString, HARNameValue{Name: name, Value: value})
+		}
+	}
+	if len(body) > 0 {
+		harReq.PostData = &HARPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(body),
+		}
+	}
+	return harReq
+}
+
+func harResponse(resp *http.Response, body []byte) HARResponse {
+	content := harDecode(resp.Header, body)
+	harResp := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" "),
+		HTTPVersion: resp.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(resp.Header),
+		Content: HARContent{
+			Size:     len(content),
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for _, cookie := range resp.Cookies() {
+		harResp.Cookies = append(harResp.Cookies, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	if utf8.Valid(content) {
+		harResp.Content.Text = string(content)
+	} else {
+		harResp.Content.Text = base64.StdEncoding.EncodeToString(content)
+		harResp.Content.Encoding = "base64"
+	}
+	return harResp
+}
+
+// harDecode undoes the Content-Encoding of body, since HAR content is
+// stored decoded. body is returned as is if it can't be decoded.
+func harDecode(header http.Header, body []byte) []byte {
+	encodings := contentEncodings(header)
+	if len(encodings) == 0 {
+		return body
+	}
+	var reader io.Reader = bytes.NewReader(body)
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		reader, err = decompressor(encodings[i], reader)
+		if err != nil {
+			return body
+		}
+	}
+	decoded, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
+
+// harHeaders flattens header into name/value pairs, sorted by name
+func harHeaders(header http.Header) []HARNameValue {
+	pairs := []HARNameValue{}
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
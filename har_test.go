@@ -0,0 +1,84 @@
+package jgh
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(201)
+		w.Write([]byte(`{"id":7}`)) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "test.har")
+	recorder := NewHARRecorder(filename)
+	client := ClientWithMiddleware(nil, recorder.Middleware)
+
+	var output struct {
+		ID int `json:"id"`
+	}
+	status, _ := RESTRequest(client, "POST", server.URL+"/users?a=1&b=2", "", "", nil, map[string]string{"name": "foo"}, &output)
+	if status != 201 || output.ID != 7 {
+		t.Error("Recording interfered with the request")
+	}
+
+	if _, err := ioutil.ReadFile(filename); !os.IsNotExist(err) {
+		t.Error("HAR file was written before Close")
+	}
+	expectNoErr(t, recorder.Close())
+	contents, err := ioutil.ReadFile(filename)
+	expectNoErr(t, err)
+	var har HAR
+	expectNoErr(t, json.Unmarshal(contents, &har))
+
+	expect(t, "1.2", har.Log.Version, "HAR version")
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(har.Log.Entries))
+	}
+	entry := har.Log.Entries[0]
+	expect(t, "POST", entry.Request.Method, "request method")
+	expect(t, `{"name":"foo"}`, entry.Request.PostData.Text, "request body")
+	expect(t, "application/json", entry.Request.PostData.MimeType, "request mime type")
+	expect(t, []HARNameValue{{"a", "1"}, {"b", "2"}}, entry.Request.QueryString, "query string")
+	expect(t, 201, entry.Response.Status, "response status")
+	expect(t, "Created", entry.Response.StatusText, "response status text")
+	expect(t, `{"id":7}`, entry.Response.Content.Text, "response body")
+}
+
+func TestHARRecorderCompressed(t *testing.T) {
+	text := strings.Repeat("hello ", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, err := compress("gzip", []byte(text))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	// below DecompressionMiddleware, the recorder sees the compressed body
+	recorder := NewHARRecorder("")
+	client := ClientWithMiddleware(nil, DecompressionMiddleware, recorder.Middleware)
+	body, _ := HTTPRequest(client, "GET", server.URL, "", "", map[string]string{"Accept-Encoding": "gzip"}, "")
+	expect(t, text, body, "decompressed body")
+
+	entries := recorder.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	expect(t, text, entries[0].Response.Content.Text, "recorded body")
+	expect(t, len(text), entries[0].Response.Content.Size, "recorded content size")
+	if entries[0].Response.BodySize >= entries[0].Response.Content.Size {
+		t.Errorf("Body size %d is not the compressed size", entries[0].Response.BodySize)
+	}
+}
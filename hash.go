@@ -0,0 +1,152 @@
+package jgh
+
+import (
+	"crypto"
+	"crypto/hmac"
+	_ "crypto/md5" // register the hashes with crypto.Hash
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// The functions in this file are a more general version of MD5. They take
+// a crypto.Hash to choose the algorithm, for example crypto.SHA256. MD5,
+// SHA-1, SHA-224, SHA-256, SHA-384 and SHA-512 are always available.
+
+// Digest is the output of a hash or HMAC, which can be formatted as
+// needed
+type Digest []byte
+
+// Hex returns the digest as lowercase hexadecimal, like MD5 does
+func (d Digest) Hex() string {
+	return hex.EncodeToString(d)
+}
+
+// Base64 returns the digest as standard, padded base 64
+func (d Digest) Base64() string {
+	return base64.StdEncoding.EncodeToString(d)
+}
+
+// Base64URL returns the digest as unpadded URL safe base 64, as used in
+// JWTs
+func (d Digest) Base64URL() string {
+	return base64.RawURLEncoding.EncodeToString(d)
+}
+
+// String returns the digest as hexadecimal
+func (d Digest) String() string {
+	return d.Hex()
+}
+
+// Equal compares two digests in constant time
+func (d Digest) Equal(other Digest) bool {
+	return hmac.Equal(d, other)
+}
+
+// HashBytes returns the hash of data
+func HashBytes(hash crypto.Hash, data []byte) Digest {
+	h := hash.New()
+	h.Write(data) // nolint: errcheck
+	return h.Sum(nil)
+}
+
+// HashString returns the hash of input
+func HashString(hash crypto.Hash, input string) Digest {
+	return HashBytes(hash, []byte(input))
+}
+
+// HashReader returns the hash of everything read from r, without holding
+// it all in memory. The error is from reading r.
+func HashReader(hash crypto.Hash, r io.Reader) (Digest, error) {
+	h := hash.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// SHA1 returns the hexadecimal SHA-1 of the input string. SHA-1 is broken
+// for security purposes, so only use it for legacy APIs that need it.
+func SHA1(input string) string {
+	return HashString(crypto.SHA1, input).Hex()
+}
+
+// SHA256 returns the hexadecimal SHA-256 of the input string
+func SHA256(input string) string {
+	return HashString(crypto.SHA256, input).Hex()
+}
+
+// SHA512 returns the hexadecimal SHA-512 of the input string
+func SHA512(input string) string {
+	return HashString(crypto.SHA512, input).Hex()
+}
+
+// HMAC returns the HMAC of data with key
+func HMAC(hash crypto.Hash, key []byte, data []byte) Digest {
+	mac := hmac.New(hash.New, key)
+	mac.Write(data) // nolint: errcheck
+	return mac.Sum(nil)
+}
+
+// HMACString returns the HMAC of input with key
+func HMACString(hash crypto.Hash, key []byte, input string) Digest {
+	return HMAC(hash, key, []byte(input))
+}
+
+// HMACReader returns the HMAC of everything read from r with key. The
+// error is from reading r.
+func HMACReader(hash crypto.Hash, key []byte, r io.Reader) (Digest, error) {
+	mac := hmac.New(hash.New, key)
+	if _, err := io.Copy(mac, r); err != nil {
+		return nil, err
+	}
+	return mac.Sum(nil), nil
+}
+
+// VerifyHMAC returns whether signature is the HMAC of data with key. The
+// comparison takes constant time, so it doesn't leak how much of the
+// signature was right.
+func VerifyHMAC(hash crypto.Hash, key []byte, data []byte, signature []byte) bool {
+	return hmac.Equal(HMAC(hash, key, data), signature)
+}
+
+// VerifyHMACString is VerifyHMAC for a signature in hexadecimal, base 64
+// or base64url (padded or not), as HTTP APIs usually send them
+func VerifyHMACString(hash crypto.Hash, key []byte, data []byte, signature string) bool {
+	decoded, ok := decodeDigest(signature, hash.Size())
+	if !ok {
+		return false
+	}
+	return VerifyHMAC(hash, key, data, decoded)
+}
+
+// decodeDigest decodes a digest of size bytes from any of the formats
+// Digest produces. The encodings are told apart by length, since a hex
+// string is also valid base 64.
+func decodeDigest(encoded string, size int) (Digest, bool) {
+	var decoded []byte
+	var err error
+	switch len(encoded) {
+	case hex.EncodedLen(size):
+		decoded, err = hex.DecodeString(encoded)
+	case base64.StdEncoding.EncodedLen(size):
+		if strings.ContainsAny(encoded, "-_") {
+			decoded, err = base64.URLEncoding.DecodeString(encoded)
+		} else {
+			decoded, err = base64.StdEncoding.DecodeString(encoded)
+		}
+	case base64.RawStdEncoding.EncodedLen(size):
+		if strings.ContainsAny(encoded, "-_") {
+			decoded, err = base64.RawURLEncoding.DecodeString(encoded)
+		} else {
+			decoded, err = base64.RawStdEncoding.DecodeString(encoded)
+		}
+	default:
+		return nil, false
+	}
+	return decoded, err == nil && len(decoded) == size
+}
@@ -0,0 +1,46 @@
+package jgh
+
+import (
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestHashHelpers(t *testing.T) {
+	expect(t, "0a4d55a8d778e5022fab701977c5d840bbc486d0", SHA1("Hello World"), "SHA-1")
+	expect(t, "a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e", SHA256("Hello World"), "SHA-256")
+	expect(t, "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e", SHA512(""), "SHA-512")
+	expect(t, MD5("Hello World"), HashString(crypto.MD5, "Hello World").Hex(), "MD5")
+
+	digest, err := HashReader(crypto.SHA256, strings.NewReader("Hello World"))
+	expectNoErr(t, err)
+	expect(t, SHA256("Hello World"), digest.String(), "SHA-256 of a reader")
+
+	readErr := errors.New("read failed")
+	_, err = HashReader(crypto.SHA256, iotest.ErrReader(readErr))
+	expect(t, readErr, err, "error from reader")
+}
+
+func TestHMAC(t *testing.T) {
+	key := []byte("key")
+	message := "The quick brown fox jumps over the lazy dog"
+	mac := HMACString(crypto.SHA256, key, message)
+	expect(t, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", mac.Hex(), "hex HMAC")
+	expect(t, "97yD9DBThCSxMpjmqm+xQ+9NWaFJRhdZl0edvC0aPNg=", mac.Base64(), "base64 HMAC")
+	expect(t, "97yD9DBThCSxMpjmqm-xQ-9NWaFJRhdZl0edvC0aPNg", mac.Base64URL(), "base64url HMAC")
+
+	fromReader, err := HMACReader(crypto.SHA256, key, strings.NewReader(message))
+	expectNoErr(t, err)
+	expect(t, true, mac.Equal(fromReader), "HMAC of a reader")
+
+	expect(t, true, VerifyHMAC(crypto.SHA256, key, []byte(message), mac), "valid HMAC")
+	expect(t, false, VerifyHMAC(crypto.SHA256, []byte("other"), []byte(message), mac), "HMAC with the wrong key")
+	for _, signature := range []string{mac.Hex(), strings.ToUpper(mac.Hex()), mac.Base64(), mac.Base64URL(), "97yD9DBThCSxMpjmqm-xQ-9NWaFJRhdZl0edvC0aPNg="} {
+		expect(t, true, VerifyHMACString(crypto.SHA256, key, []byte(message), signature), "signature "+signature)
+	}
+	for _, signature := range []string{"", "nope", mac.Hex()[1:], strings.Replace(mac.Hex(), "f", "e", 1)} {
+		expect(t, false, VerifyHMACString(crypto.SHA256, key, []byte(message), signature), "signature "+signature)
+	}
+}
@@ -0,0 +1,217 @@
+package jgh
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricHTTPHedges counts the extra attempts started by HedgingMiddleware
+const MetricHTTPHedges = "jgh_http_hedged_requests_total"
+
+// HedgePolicy controls when HedgingMiddleware starts extra attempts
+type HedgePolicy struct {
+	// Delay is how long to wait for a response before starting another
+	// attempt
+	Delay time.Duration
+	// Percentile, if set (for example 0.95), replaces Delay with that
+	// percentile of recent response times, once at least MinSamples
+	// responses have been seen. Until then Delay is used.
+	Percentile float64
+	// MinSamples is the number of responses needed before Percentile is
+	// used. Defaults to 20.
+	MinSamples int
+	// MaxAttempts is the most attempts that will be in flight for a single
+	// request, including the first one. Defaults to 2.
+	MaxAttempts int
+}
+
+// HedgingMiddleware sends a second copy of a request if the first has not
+// completed within the policy's delay, and returns whichever response
+// succeeds first (no error and a status below 500). The other attempts are
+// cancelled. If an attempt fails, the next one is started immediately. If
+// every attempt fails, the last failure is returned.
+//
+// Only GET, HEAD, OPTIONS and TRACE requests are hedged, since sending a
+// request more than once must be harmless. Other requests are passed
+// through unmodified.
+func HedgingMiddleware(policy HedgePolicy) Middleware {
+	if policy.MaxAttempts < 2 {
+		policy.MaxAttempts = 2
+	}
+	if policy.MinSamples < 1 {
+		policy.MinSamples = 20
+	}
+	latencies := &latencyTracker{size: 100}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			switch req.Method {
+			case "GET", "HEAD", "OPTIONS", "TRACE":
+			default:
+				return next.RoundTrip(req)
+			}
+
+			delay := policy.Delay
+			if policy.Percentile > 0 {
+				if percentile, ok := latencies.percentile(policy.Percentile, policy.MinSamples); ok {
+					delay = percentile
+				}
+			}
+
+			results := make(chan hedgeResult, policy.MaxAttempts)
+			var cancels []context.CancelFunc
+			launch := func() {
+				ctx, cancel := context.WithCancel(req.Context())
+				index := len(cancels)
+				cancels = append(cancels, cancel)
+				attemptReq := req.Clone(ctx)
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						results <- hedgeResult{err: err, index: index, cancel: cancel}
+						return
+					}
+					attemptReq.Body = body
+				}
+				go func() {
+					start := time.Now()
+					resp, err := next.RoundTrip(attemptReq)
+					if err == nil && resp.StatusCode < 500 {
+						latencies.add(time.Since(start))
+					}
+					results <- hedgeResult{resp, err, index, cancel}
+				}()
+			}
+
+			launch()
+			launched, pending := 1, 1
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+
+			var last hedgeResult
+			for pending > 0 {
+				select {
+				case <-timer.C:
+					if launched < policy.MaxAttempts {
+						addCounter(MetricHTTPHedges, Labels{"method": req.Method}, 1)
+						launch()
+						launched++
+						pending++
+						timer.Reset(delay)
+					}
+				case r := <-results:
+					pending--
+					if r.err == nil && r.resp.StatusCode < 500 {
+						// cancel the losers, but keep our own context alive
+						// until the caller is done with the body
+						for i, cancel := range cancels {
+							if i != r.index {
+								cancel()
+							}
+						}
+						go drainHedges(results, pending)
+						r.resp.Body = cancelOnClose(r.resp.Body, r.cancel)
+						return r.resp, nil
+					}
+
+					// this attempt failed, keep it in case they all do
+					if last.resp != nil {
+						last.resp.Body.Close() // nolint: errcheck
+					}
+					if last.cancel != nil {
+						last.cancel()
+					}
+					last = r
+					if launched < policy.MaxAttempts && req.Context().Err() == nil {
+						launch()
+						launched++
+						pending++
+					}
+				}
+			}
+
+			if last.resp != nil {
+				last.resp.Body = cancelOnClose(last.resp.Body, last.cancel)
+			} else {
+				last.cancel()
+			}
+			return last.resp, last.err
+		})
+	}
+}
+
+// hedgeResult is the outcome of one attempt made by HedgingMiddleware
+type hedgeResult struct {
+	resp   *http.Response
+	err    error
+	index  int
+	cancel context.CancelFunc
+}
+
+// drainHedges closes the responses of attempts that lost the race
+func drainHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		r := <-results
+		if r.resp != nil {
+			r.resp.Body.Close() // nolint: errcheck
+		}
+		r.cancel()
+	}
+}
+
+// cancelOnClose calls cancel when body is closed
+func cancelOnClose(body io.ReadCloser, cancel context.CancelFunc) io.ReadCloser {
+	return readCloser{body, closerFunc(func() error {
+		err := body.Close()
+		cancel()
+		return err
+	})}
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// latencyTracker keeps the most recent size response times
+type latencyTracker struct {
+	size    int
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (t *latencyTracker) add(d time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.samples) < t.size {
+		t.samples = append(t.samples, d)
+		return
+	}
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % t.size
+}
+
+// percentile returns the p percentile (0 to 1) of the recorded samples,
+// or false if there are fewer than minSamples
+func (t *latencyTracker) percentile(p float64, minSamples int) (time.Duration, bool) {
+	t.mutex.Lock()
+	sorted := append([]time.Duration(nil), t.samples...)
+	t.mutex.Unlock()
+	if len(sorted) < minSamples || len(sorted) == 0 {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p * float64(len(sorted)-1))
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], true
+}
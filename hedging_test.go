@@ -0,0 +1,75 @@
+package jgh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgingMiddleware(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// the first request is stuck until it is cancelled
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			w.Write([]byte("slow")) // nolint: errcheck
+			return
+		}
+		w.Write([]byte("fast")) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	client := ClientWithMiddleware(nil, HedgingMiddleware(HedgePolicy{Delay: 20 * time.Millisecond}))
+	start := time.Now()
+	resp, status := HTTPRequest(client, "GET", server.URL, "", "", nil, "")
+	if time.Since(start) > 2*time.Second {
+		t.Error("Hedged request waited for the slow attempt")
+	}
+	expect(t, 200, status, "status")
+	expect(t, "fast", resp, "response body")
+	expect(t, int32(2), atomic.LoadInt32(&requests), "number of requests")
+
+	// POST is not idempotent, so it must not be hedged
+	atomic.StoreInt32(&requests, 1)
+	resp, _ = HTTPRequest(client, "POST", server.URL, "", "", nil, "body")
+	expect(t, "fast", resp, "response body")
+	expect(t, int32(2), atomic.LoadInt32(&requests), "number of requests")
+}
+
+func TestHedgingFailover(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte("ok")) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	// a failure starts the next attempt without waiting for the delay
+	client := ClientWithMiddleware(nil, HedgingMiddleware(HedgePolicy{Delay: time.Hour}))
+	resp, status := HTTPRequest(client, "GET", server.URL, "", "", nil, "")
+	expect(t, 200, status, "status")
+	expect(t, "ok", resp, "response body")
+}
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := &latencyTracker{size: 10}
+	if _, ok := tracker.percentile(0.5, 1); ok {
+		t.Error("Empty tracker returned a percentile")
+	}
+	for i := 1; i <= 20; i++ {
+		tracker.add(time.Duration(i) * time.Millisecond)
+	}
+	// only the last 10 samples (11ms to 20ms) are kept
+	p, _ := tracker.percentile(0, 1)
+	expect(t, 11*time.Millisecond, p, "minimum")
+	p, _ = tracker.percentile(1, 1)
+	expect(t, 20*time.Millisecond, p, "maximum")
+}
@@ -1,12 +1,13 @@
 package jgh
 
 import (
+	"context"
 	"crypto/md5"
 	cryptoRand "crypto/rand"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"io/ioutil"
 	"log"
@@ -20,6 +21,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
@@ -136,11 +138,36 @@ func initPtr(ptrPtrIface interface{}) {
 // "msg (will retry up to t times)" for each try panicMsg contains the
 // value from recover from the most recent panic
 func Try(interval int, tries int, allowPanic bool, msg string, f func() bool) (success bool, panicMsg interface{}) { // nolint: deadcode, megacheck
+	return TryContext(context.Background(), interval, tries, allowPanic, msg, func(context.Context) bool {
+		return f()
+	})
+}
+
+// TryContext is like Try, but stops retrying once ctx is done. A span is
+// started (using Tracing) around the whole call and each attempt, and f
+// is given the context of its attempt's span. DefaultTryHooks are called
+// as attempts fail.
+func TryContext(ctx context.Context, interval int, tries int, allowPanic bool, msg string, f func(ctx context.Context) bool) (success bool, panicMsg interface{}) {
+	return TryWithHooks(ctx, interval, tries, allowPanic, msg, DefaultTryHooks, f)
+}
+
+// TryWithHooks is like TryContext, but calls hooks instead of
+// DefaultTryHooks as attempts fail
+func TryWithHooks(ctx context.Context, interval int, tries int, allowPanic bool, msg string, hooks TryHooks, f func(ctx context.Context) bool) (success bool, panicMsg interface{}) {
 	// if tries is negitive, we retry forever
 	infinite := tries < 0
 	loggingEnabled := len(msg) > 0
+	delay := time.Duration(interval) * time.Second
+
+	ctx, span := Tracing.Start(ctx, "Try")
+	defer span.End()
+	if loggingEnabled {
+		span.SetAttribute("try.msg", msg)
+	}
 
-	for ; tries > 0 || infinite; tries-- {
+	var attempt int
+	var attemptErr error
+	for attempt = 1; tries > 0 || infinite; tries-- {
 		if loggingEnabled {
 			if tries < 0 {
 				Logger.Printf("%s (try %d)", msg, -tries)
@@ -151,33 +178,59 @@ func Try(interval int, tries int, allowPanic bool, msg string, f func() bool) (s
 
 		// we have to have a new function, because one the panic in f() makes it
 		// to our function, there is no hope of normal continued execution here
+		attemptErr = nil
 		func() {
+			attemptCtx, attemptSpan := Tracing.Start(ctx, "Try attempt")
+			attemptSpan.SetAttribute("try.attempt", attempt)
+			defer attemptSpan.End()
+
 			// this makes sure we don't panic if f() does
 			defer func() {
 				// if we are on our last iteration, let the panic continue to bubble up
 				if tries > 1 || !allowPanic {
 					panicMsg = recover()
+					if panicMsg != nil {
+						attemptErr = &PanicError{Value: panicMsg, Stack: debug.Stack()}
+						addCounter(MetricTryPanics, nil, 1)
+						attemptSpan.RecordError(attemptErr)
+						hooks.panic(TryEvent{Msg: msg, Attempt: attempt, Err: attemptErr})
+					}
 					if panicMsg != nil && loggingEnabled {
-						Logger.Printf("Panic while %s: %v", msg, panicMsg)
-						debug.PrintStack()
+						Logger.Printf("Panic while %s: %v\n%s", msg, panicMsg, attemptErr.(*PanicError).Stack)
 					}
 				}
 			}()
 
-			success = f()
+			addCounter(MetricTryAttempts, nil, 1)
+			success = f(attemptCtx)
 		}()
+		span.SetAttribute("try.attempts", attempt)
 
 		if success {
 			// f() was successful
+			addCounter(MetricTryCalls, Labels{"result": "success"}, 1)
 			return
 		}
 
 		// no point in sleeping if we are not going to retry f()
 		if tries > 1 || infinite {
-			time.Sleep(time.Duration(interval) * time.Second)
+			addCounter(MetricTryRetries, nil, 1)
+			hooks.retry(TryEvent{Msg: msg, Attempt: attempt, NextDelay: delay, Err: attemptErr})
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				span.RecordError(ctx.Err())
+				addCounter(MetricTryCalls, Labels{"result": "failure"}, 1)
+				hooks.giveUp(TryEvent{Msg: msg, Attempt: attempt, Err: ctx.Err()})
+				return
+			}
 		}
+		attempt++
 	}
 	// we have run f() t times without success
+	span.RecordError(errors.New("gave up after all tries failed"))
+	addCounter(MetricTryCalls, Labels{"result": "failure"}, 1)
+	hooks.giveUp(TryEvent{Msg: msg, Attempt: attempt - 1, Err: attemptErr})
 	return
 }
 
@@ -204,56 +257,49 @@ func HTTPClient(cookieJar bool, followRedirects bool) (client *http.Client) {
 	return
 }
 
+// HTTPRequest performs an HTTP request and returns the response body and
+// status code. The request is sent through Middlewares, which by default
+// handle logging, the User-Agent header and Content-Length. If user or pass
+// is set, basic auth is added as the innermost middleware.
 func HTTPRequest(client *http.Client, method string, url string, user string, pass string, headers map[string]string, reqBody string) (respBody string, status int) {
-	Logger.Printf("HTTP %s %s", method, url)
-
-	// empty string indicates no request body
-	hasBody := len(reqBody) > 0
-	if hasBody {
-		Logger.Println("Request Body: ", reqBody)
-	}
+	return HTTPRequestContext(context.Background(), client, method, url, user, pass, headers, reqBody)
+}
 
+// HTTPRequestContext is like HTTPRequest, but the request is made with ctx,
+// so it can be cancelled and carries any trace context in ctx
+func HTTPRequestContext(ctx context.Context, client *http.Client, method string, url string, user string, pass string, headers map[string]string, reqBody string) (respBody string, status int) {
 	// turn the request body into an io.Reader
+	// empty string indicates no request body
 	var reqBodyReader io.Reader
-	if hasBody {
+	if len(reqBody) > 0 {
 		reqBodyReader = strings.NewReader(reqBody)
 	} else {
 		reqBodyReader = nil
 	}
 
 	// create a new request object
-	req, err := http.NewRequest(method, url, reqBodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBodyReader)
 	if err != nil {
 		panic("Failed to create request object")
 	}
 
-	if headers == nil {
-		headers = make(map[string]string)
-	}
-
-	// add useragent (if one wasn't specified)
-	if _, keyExists := headers["User-Agent"]; !keyExists {
-		req.Header.Add("User-Agent", HTTPUserAgent)
-	}
-
 	// add request headers
-	if hasBody {
-		// even if the user set a content length, replace it with ours
-		headers["Content-Length"] = strconv.Itoa(len(reqBody))
-	}
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
 
 	// add auth if present
+	middlewares := Middlewares
 	if len(user) > 0 || len(pass) > 0 {
-		req.SetBasicAuth(user, pass)
+		// copy so we don't modify the package-level chain
+		middlewares = append(middlewares[:len(middlewares):len(middlewares)], BasicAuthMiddleware(user, pass))
 	}
 
 	// make new http client if none specified
 	if client == nil {
 		client = HTTPClient(false, true)
 	}
+	client = ClientWithMiddleware(client, middlewares...)
 
 	// perform the http request
 	resp, err := client.Do(req)
@@ -271,12 +317,25 @@ func HTTPRequest(client *http.Client, method string, url string, user string, pa
 		panic("Failed to read response body from http request")
 	}
 	respBody = string(bytes)
-	Logger.Println("Response Body: ", respBody)
 
 	return
 }
 
 func RESTRequest(client *http.Client, method string, url string, user string, pass string, headers map[string]string, input interface{}, outputPtr interface{}) (status int, reflection bool) {
+	return RESTRequestContext(context.Background(), client, method, url, user, pass, headers, input, outputPtr)
+}
+
+// RESTRequestContext is like RESTRequest, but the request is made with ctx
+func RESTRequestContext(ctx context.Context, client *http.Client, method string, url string, user string, pass string, headers map[string]string, input interface{}, outputPtr interface{}) (status int, reflection bool) {
+	status, reflection, err := restRequest(ctx, client, method, url, user, pass, headers, input, outputPtr)
+	PanicOnErr(err)
+	return
+}
+
+// restRequest does the work of RESTRequestContext, but returns errors
+// decoding the response instead of panicking, so the status code isn't
+// lost when an error response isn't JSON
+func restRequest(ctx context.Context, client *http.Client, method string, url string, user string, pass string, headers map[string]string, input interface{}, outputPtr interface{}) (status int, reflection bool, err error) {
 	hasInput := input != nil
 	hasOutput := outputPtr != nil
 
@@ -290,20 +349,23 @@ func RESTRequest(client *http.Client, method string, url string, user string, pa
 		jsonStr = string(bytes)
 	}
 
-	if headers == nil {
-		headers = make(map[string]string)
+	// copy the headers before adding defaults, since the caller's map may
+	// be shared with other requests (for example by RESTBatch)
+	requestHeaders := make(map[string]string, len(headers)+2)
+	for key, value := range headers {
+		requestHeaders[key] = value
 	}
 
 	// defaults for content-type and accept
-	if _, keyExists := headers["Content-Type"]; hasInput && !keyExists {
-		headers["Content-Type"] = "application/json"
+	if _, keyExists := requestHeaders["Content-Type"]; hasInput && !keyExists {
+		requestHeaders["Content-Type"] = "application/json"
 	}
-	if _, keyExists := headers["Accept"]; (hasInput || hasOutput) && !keyExists {
-		headers["Accept"] = "application/json"
+	if _, keyExists := requestHeaders["Accept"]; (hasInput || hasOutput) && !keyExists {
+		requestHeaders["Accept"] = "application/json"
 	}
 
 	// perform the request
-	respStr, status := HTTPRequest(client, method, url, user, pass, headers, jsonStr)
+	respStr, status := HTTPRequestContext(ctx, client, method, url, user, pass, requestHeaders, jsonStr)
 
 	// even if the user dosen't want output, we still need a place to store
 	// it so we can check for reflection
@@ -313,24 +375,31 @@ func RESTRequest(client *http.Client, method string, url string, user string, pa
 
 	if hasInput || hasOutput {
 		bytes := []byte(respStr)
-		err := json.Unmarshal(bytes, outputPtr)
-		PanicOnErr(err)
+		if err := json.Unmarshal(bytes, outputPtr); err != nil {
+			return status, false, err
+		}
 	}
 
 	if hasInput {
 		// many calls return the input as output on success, so we check for this here
 		output, err := DerefrenceInterface(outputPtr)
-		PanicOnErr(err)
+		if err != nil {
+			return status, false, err
+		}
 
 		reflection = reflect.DeepEqual(input, output)
 	}
 
-	return
+	return status, reflection, nil
 }
 
-func Expect(expected interface{}, input interface{}, name string) {
-	if !reflect.DeepEqual(input, expected) {
-		msg := fmt.Sprintf("Expected %s to be %v, got %v", name, expected, input)
+// Expect panics if input is not deeply equal to expected. The panic
+// message lists the path to every field, map key or slice index that
+// differs (see Diff). opts relax the comparison, for example to allow for
+// float rounding (see CompareOption).
+func Expect(expected interface{}, input interface{}, name string, opts ...CompareOption) {
+	if expectationErr := compare(expected, input, name, opts); expectationErr != nil {
+		msg := expectationErr.Error()
 		panic(msg)
 	}
 }
@@ -344,6 +413,7 @@ func PanicOnErr(err error) {
 }
 
 // detect an error, and throws a diffrent message
+// the original error is only logged, use Wrap to keep it
 func RenameErr(err error, newErrMsg string) {
 	if err != nil {
 		_, filename, line, _ := runtime.Caller(1)
@@ -353,45 +423,87 @@ func RenameErr(err error, newErrMsg string) {
 	}
 }
 
-type cryptoSource struct{}
+// cryptoSource is a math/rand source backed by crypto/rand. Random bytes
+// are read in large chunks, since each read from crypto/rand is a system
+// call. It is safe for concurrent use.
+type cryptoSource struct {
+	mutex sync.Mutex
+	buf   [4096]byte
+	pos   int
+}
+
+func newCryptoSource() *cryptoSource {
+	// start with an empty buffer, so the first call fills it
+	return &cryptoSource{pos: len(cryptoSource{}.buf)}
+}
 
-func (s cryptoSource) Seed(seed int64) {}
+func (s *cryptoSource) Seed(seed int64) {}
 
-func (s cryptoSource) Int63() int64 {
+func (s *cryptoSource) Int63() int64 {
 	return int64(s.Uint64() & ^uint64(1<<63))
 }
 
-func (s cryptoSource) Uint64() (v uint64) {
-	err := binary.Read(cryptoRand.Reader, binary.BigEndian, &v)
-	if err != nil {
-		panic(err)
+func (s *cryptoSource) Uint64() (v uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pos+8 > len(s.buf) {
+		_, err := io.ReadFull(cryptoRand.Reader, s.buf[:])
+		if err != nil {
+			panic(err)
+		}
+		s.pos = 0
 	}
+	v = binary.BigEndian.Uint64(s.buf[s.pos:])
+	// don't keep random values around once they have been used
+	binary.BigEndian.PutUint64(s.buf[s.pos:], 0)
+	s.pos += 8
 	return v
 }
 
-// Rand is a math/rand object that is cryptographically secure
+// Uint64n returns a uniformly distributed random number in [0, n). Values
+// from the top of the range that would make some results more likely than
+// others (modulo bias) are rejected and redrawn.
+func (s *cryptoSource) Uint64n(n uint64) uint64 {
+	if n == 0 {
+		panic("Uint64n: n must be greater than 0")
+	}
+	// the largest multiple of n that fits in a uint64 is 2^64 - (2^64 % n)
+	// and 2^64 % n == (2^64 - n) % n, which we can compute without overflow
+	limit := -(-n % n)
+	for {
+		v := s.Uint64()
+		if limit == 0 || v < limit {
+			return v % n
+		}
+	}
+}
+
+// secureSource is shared by Rand and the other random helpers
+var secureSource = newCryptoSource()
+
+// Rand is a math/rand object that is cryptographically secure. Like any
+// *math/rand.Rand it is not safe for concurrent use (its Read method keeps
+// state), so code running on several goroutines should use
+// RandomIntn, RandomFloat64, RandomShuffle and the like instead.
 var Rand *mathRand.Rand
 
 func init() {
-	Rand = mathRand.New(cryptoSource{})
+	Rand = mathRand.New(secureSource)
 }
 
 // RandomString returns a random string of the specified length which may
 // contain upper and lowercase letters and numbers. It uses a secure source
 // or randomness.
 func RandomString(n int) string {
-	const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letterBytes[Rand.Intn(len(letterBytes))]
-	}
-	return string(b)
+	return RandomStringFrom(n, AlphanumericAlphabet)
 }
 
 // Status gets a positive number at the begining of a string
 // returns -1 on failure
 // the intended use is to pull status codes from strings like:
 // 404 unable to locate your thing
+// See ParseStatus and StatusOf for codes elsewhere in the string or in an
+// error chain.
 func Status(errStr string) int {
 	var endPos int
 	var char rune
@@ -422,7 +534,7 @@ func ReadAll(reader io.Reader) (contents string) {
 }
 
 // MD5 returns the hexadecimal representation of the MD5
-// sum of the input string
+// sum of the input string. See HashString for other algorithms.
 func MD5(input string) string {
 	hashBytes := md5.Sum([]byte(input))
 	hashHex := hex.EncodeToString(hashBytes[:])
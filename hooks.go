@@ -0,0 +1,59 @@
+package jgh
+
+import (
+	"time"
+)
+
+// TryEvent describes something that happened during Try, TryContext,
+// Retry or RetryContext
+type TryEvent struct {
+	// Msg is the msg argument that was passed in
+	Msg string
+	// Attempt is the number of the attempt the event is about, starting at
+	// 1. For OnGiveUp it is the total number of attempts made.
+	Attempt int
+	// NextDelay is how long until the next attempt. It is only set for
+	// OnRetry.
+	NextDelay time.Duration
+	// Err is the error from the attempt. For a panic it is a *PanicError,
+	// which includes the stack trace. It is nil when Try's f returned
+	// false.
+	Err error
+}
+
+// TryHooks are callbacks for watching retries as they happen. Any of them
+// may be nil.
+type TryHooks struct {
+	// OnRetry is called after a failed attempt, before waiting NextDelay
+	// to try again
+	OnRetry func(event TryEvent)
+	// OnPanic is called when an attempt panics and the panic is recovered
+	OnPanic func(event TryEvent)
+	// OnGiveUp is called when the last attempt fails, or the context is
+	// done, and no more attempts will be made
+	OnGiveUp func(event TryEvent)
+}
+
+// DefaultTryHooks are used by Try, TryContext, Retry and RetryContext.
+// Changing them affects every caller in the process, so only set them
+// once at startup. To watch a single call, pass hooks to TryWithHooks or
+// RetryWithHooks instead.
+var DefaultTryHooks TryHooks
+
+func (hooks TryHooks) retry(event TryEvent) {
+	if hooks.OnRetry != nil {
+		hooks.OnRetry(event)
+	}
+}
+
+func (hooks TryHooks) panic(event TryEvent) {
+	if hooks.OnPanic != nil {
+		hooks.OnPanic(event)
+	}
+}
+
+func (hooks TryHooks) giveUp(event TryEvent) {
+	if hooks.OnGiveUp != nil {
+		hooks.OnGiveUp(event)
+	}
+}
@@ -0,0 +1,82 @@
+package jgh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTryHooks(t *testing.T) {
+	var events []string
+	var nextDelay time.Duration
+	hooks := TryHooks{
+		OnRetry: func(event TryEvent) {
+			events = append(events, "retry")
+			nextDelay = event.NextDelay
+		},
+		OnPanic: func(event TryEvent) {
+			var panicErr *PanicError
+			if !errors.As(event.Err, &panicErr) || len(panicErr.Stack) == 0 {
+				t.Error("OnPanic did not get a PanicError with a stack")
+			}
+			events = append(events, "panic")
+		},
+		OnGiveUp: func(event TryEvent) {
+			expect(t, 2, event.Attempt, "attempts when giving up")
+			events = append(events, "give up")
+		},
+	}
+	TryWithHooks(context.Background(), 0, 2, false, "", hooks, func(ctx context.Context) bool {
+		panic("AAAAAH!")
+	})
+	expect(t, []string{"panic", "retry", "panic", "give up"}, events, "Try events")
+	expect(t, time.Duration(0), nextDelay, "next delay")
+
+	events = nil
+	RetryWithHooks(context.Background(), 0, 2, "", nil, hooks, func(ctx context.Context) error { // nolint: errcheck
+		return io.EOF
+	})
+	expect(t, []string{"retry", "give up"}, events, "Retry events")
+}
+
+func TestTryStackTraceLogged(t *testing.T) {
+	var logged bytes.Buffer
+	oldLogger := Logger
+	defer func() { Logger = oldLogger }()
+	Logger = log.New(&logged, "", 0)
+
+	Try(0, 1, false, "testing stack traces", func() bool {
+		panic("AAAAAH!")
+	})
+	if !strings.Contains(logged.String(), "goroutine") || !strings.Contains(logged.String(), "TestTryStackTraceLogged") {
+		t.Errorf("Stack trace was not sent to Logger:\n%s", logged.String())
+	}
+}
+
+func TestTryWithHooksConcurrent(t *testing.T) {
+	// each call only sees its own hooks, without touching DefaultTryHooks
+	var wg sync.WaitGroup
+	counts := make([]int, 8)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			hooks := TryHooks{OnRetry: func(TryEvent) { counts[i]++ }}
+			TryWithHooks(context.Background(), 0, i+1, false, "", hooks, func(ctx context.Context) bool {
+				return false
+			})
+		}(i)
+	}
+	wg.Wait()
+	for i, count := range counts {
+		if count != i {
+			t.Errorf("Call %d saw %d retries, expected %d", i, count, i)
+		}
+	}
+}
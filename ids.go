@@ -0,0 +1,292 @@
+package jgh
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidUUID is returned by ParseUUID for a string that is not a UUID
+var ErrInvalidUUID = errors.New("invalid UUID")
+
+// ErrInvalidULID is returned by ParseULID for a string that is not a ULID
+var ErrInvalidULID = errors.New("invalid ULID")
+
+// idNow is the clock for time based IDs, replaced in tests
+var idNow = time.Now
+
+// randomBytes fills b from the package's secure source
+func randomBytes(b []byte) {
+	var chunk [8]byte
+	for i := 0; i < len(b); i += 8 {
+		binary.BigEndian.PutUint64(chunk[:], secureSource.Uint64())
+		copy(b[i:], chunk[:])
+	}
+}
+
+// UUID is a universally unique identifier (RFC 9562)
+type UUID [16]byte
+
+// NewUUIDv4 returns a random (version 4) UUID
+func NewUUIDv4() (u UUID) {
+	randomBytes(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// uuidV7State is the last version 7 UUID handed out, split into its
+// timestamp and the 12 + 62 random bits that follow it
+var uuidV7State struct {
+	mutex  sync.Mutex
+	millis int64
+	randA  uint16
+	randB  uint64
+}
+
+// NewUUIDv7 returns a time ordered (version 7) UUID, which starts with
+// the current Unix time in milliseconds. UUIDs from the same process are
+// strictly increasing: within the same millisecond (or if the clock goes
+// backwards) the random bits of the previous UUID are incremented.
+func NewUUIDv7() (u UUID) {
+	state := &uuidV7State
+	state.mutex.Lock()
+	millis := idNow().UnixMilli()
+	if millis > state.millis {
+		state.millis = millis
+		// leave the top bit of the counter clear, so it has room to grow
+		state.randA = uint16(secureSource.Uint64n(1 << 11))
+		state.randB = secureSource.Uint64n(1 << 62)
+	} else {
+		state.randB++
+		if state.randB == 1<<62 {
+			state.randB = 0
+			state.randA++
+			if state.randA == 1<<12 {
+				// out of numbers for this millisecond, borrow the next one
+				state.millis++
+				state.randA = uint16(secureSource.Uint64n(1 << 11))
+				state.randB = secureSource.Uint64n(1 << 62)
+			}
+		}
+	}
+	millis, randA, randB := state.millis, state.randA, state.randB
+	state.mutex.Unlock()
+
+	var timestamp [8]byte
+	binary.BigEndian.PutUint64(timestamp[:], uint64(millis))
+	copy(u[0:6], timestamp[2:])
+	binary.BigEndian.PutUint16(u[6:8], 0x7000|randA)
+	binary.BigEndian.PutUint64(u[8:16], 1<<63|randB)
+	return u
+}
+
+// ParseUUID parses a UUID in the standard 8-4-4-4-12 hex format, in upper
+// or lower case, optionally with a "urn:uuid:" prefix or in braces
+func ParseUUID(s string) (u UUID, err error) {
+	switch {
+	case len(s) == 45 && strings.EqualFold(s[:9], "urn:uuid:"):
+		s = s[9:]
+	case len(s) == 38 && s[0] == '{' && s[37] == '}':
+		s = s[1:37]
+	}
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, ErrInvalidUUID
+	}
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUID{}, ErrInvalidUUID
+	}
+	return u, nil
+}
+
+// IsValidUUID returns whether s can be parsed by ParseUUID
+func IsValidUUID(s string) bool {
+	_, err := ParseUUID(s)
+	return err == nil
+}
+
+// String formats u in the standard lowercase 8-4-4-4-12 format
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:36], u[10:16])
+	return string(b[:])
+}
+
+// Version returns the UUID version, for example 4 or 7
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// Time returns the time stored in a version 7 UUID, or the zero time for
+// other versions
+func (u UUID) Time() time.Time {
+	if u.Version() != 7 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(u[:8]) >> 16))
+}
+
+// MarshalText implements encoding.TextMarshaler, so UUIDs are strings in
+// JSON
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (u *UUID) UnmarshalText(text []byte) (err error) {
+	*u, err = ParseUUID(string(text))
+	return err
+}
+
+// ULID is a universally unique lexicographically sortable identifier: a
+// 48 bit Unix time in milliseconds followed by 80 random bits, written as
+// 26 characters of Crockford's base 32
+type ULID [16]byte
+
+// ulidState is the last ULID handed out
+var ulidState struct {
+	mutex  sync.Mutex
+	millis int64
+	random [10]byte
+}
+
+// NewULID returns a ULID for the current time. Like NewUUIDv7, ULIDs from
+// the same process are strictly increasing.
+func NewULID() (id ULID) {
+	state := &ulidState
+	state.mutex.Lock()
+	millis := idNow().UnixMilli()
+	if millis > state.millis {
+		state.millis = millis
+		randomBytes(state.random[:])
+	} else if !incrementBytes(state.random[:]) {
+		// out of numbers for this millisecond, borrow the next one
+		state.millis++
+		randomBytes(state.random[:])
+	}
+	var timestamp [8]byte
+	binary.BigEndian.PutUint64(timestamp[:], uint64(state.millis))
+	copy(id[0:6], timestamp[2:])
+	copy(id[6:], state.random[:])
+	state.mutex.Unlock()
+	return id
+}
+
+// incrementBytes adds 1 to b as a big endian number, and returns false if
+// it overflowed
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseULID parses a ULID in upper or lower case. As Crockford's base 32
+// allows, I and L are read as 1 and O as 0.
+func ParseULID(s string) (id ULID, err error) {
+	// the first character only holds 3 bits, since 26 * 5 = 130
+	if len(s) != 26 || s[0] > '7' {
+		return id, ErrInvalidULID
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		value := crockfordValue(s[i])
+		if value < 0 {
+			return ULID{}, ErrInvalidULID
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(value)
+	}
+	binary.BigEndian.PutUint64(id[0:8], hi)
+	binary.BigEndian.PutUint64(id[8:16], lo)
+	return id, nil
+}
+
+// crockfordValue returns the value of a base 32 digit, or -1 if c isn't one
+func crockfordValue(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	switch c {
+	case 'I', 'L':
+		return 1
+	case 'O':
+		return 0
+	}
+	return strings.IndexByte(Base32CrockfordAlphabet, c)
+}
+
+// IsValidULID returns whether s can be parsed by ParseULID
+func IsValidULID(s string) bool {
+	_, err := ParseULID(s)
+	return err == nil
+}
+
+// String formats id as 26 uppercase characters
+func (id ULID) String() string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+	var b [26]byte
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = Base32CrockfordAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(b[:])
+}
+
+// Time returns the time stored in id
+func (id ULID) Time() time.Time {
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(id[:8]) >> 16))
+}
+
+// MarshalText implements encoding.TextMarshaler, so ULIDs are strings in
+// JSON
+func (id ULID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (id *ULID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseULID(string(text))
+	return err
+}
+
+// NanoIDLength is the length of the IDs from NewNanoID, which gives about
+// 126 bits of entropy
+const NanoIDLength = 21
+
+// NewNanoID returns a random Nano ID: 21 characters from URLSafeAlphabet,
+// the same alphabet the nanoid library uses. For a different length or
+// alphabet, use RandomStringFrom.
+func NewNanoID() string {
+	return RandomStringFrom(NanoIDLength, URLSafeAlphabet)
+}
+
+// IsValidNanoID returns whether s looks like an ID from NewNanoID
+func IsValidNanoID(s string) bool {
+	if len(s) != NanoIDLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(URLSafeAlphabet, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package jgh
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestUUIDv4(t *testing.T) {
+	u := NewUUIDv4()
+	expect(t, 4, u.Version(), "version")
+	expect(t, byte(0x80), u[8]&0xc0, "variant bits")
+	if u == NewUUIDv4() {
+		t.Error("Two random UUIDs were the same")
+	}
+
+	parsed, err := ParseUUID(u.String())
+	expectNoErr(t, err)
+	expect(t, u, parsed, "parsed UUID")
+}
+
+func TestUUIDv7(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	u := NewUUIDv7()
+	expect(t, 7, u.Version(), "version")
+	expect(t, byte(0x80), u[8]&0xc0, "variant bits")
+	if u.Time().Before(before) || u.Time().After(time.Now()) {
+		t.Errorf("UUID time %v is not the current time", u.Time())
+	}
+	expect(t, time.Time{}, NewUUIDv4().Time(), "time of a version 4 UUID")
+}
+
+func TestParseUUID(t *testing.T) {
+	expected := UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	for _, s := range []string{
+		"123e4567-e89b-12d3-a456-426614174000",
+		"123E4567-E89B-12D3-A456-426614174000",
+		"urn:uuid:123e4567-e89b-12d3-a456-426614174000",
+		"{123e4567-e89b-12d3-a456-426614174000}",
+	} {
+		u, err := ParseUUID(s)
+		expect(t, nil, err, "error parsing "+s)
+		expect(t, expected, u, s)
+	}
+	expect(t, "123e4567-e89b-12d3-a456-426614174000", expected.String(), "formatted UUID")
+
+	for _, s := range []string{
+		"",
+		"123e4567e89b12d3a456426614174000",
+		"123e4567-e89b-12d3-a456-42661417400g",
+		"123e4567-e89b-12d3-a456_426614174000",
+		"{123e4567-e89b-12d3-a456-426614174000",
+	} {
+		if IsValidUUID(s) {
+			t.Errorf("%q was accepted as a UUID", s)
+		}
+	}
+}
+
+func TestULID(t *testing.T) {
+	id := NewULID()
+	s := id.String()
+	expect(t, 26, len(s), "ULID length")
+	if time.Since(id.Time()) > time.Second {
+		t.Errorf("ULID time %v is not the current time", id.Time())
+	}
+
+	parsed, err := ParseULID(s)
+	expectNoErr(t, err)
+	expect(t, id, parsed, "parsed ULID")
+
+	// example from the ULID spec
+	parsed, err = ParseULID("01arz3ndektsv4rrffq69g5fav")
+	expectNoErr(t, err)
+	expect(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", parsed.String(), "formatted ULID")
+	expect(t, int64(1469922850259), parsed.Time().UnixMilli(), "ULID time")
+
+	o, err := ParseULID("0IARZ3NDEKTSV4RRFFQ69G5FAV")
+	expectNoErr(t, err)
+	expect(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", o.String(), "ULID with I for 1")
+
+	for _, s := range []string{"", "01ARZ3NDEKTSV4RRFFQ69G5FA", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+		if IsValidULID(s) {
+			t.Errorf("%q was accepted as a ULID", s)
+		}
+	}
+}
+
+func TestIDsMonotonic(t *testing.T) {
+	// a clock that is stuck, then goes backwards
+	now := time.UnixMilli(1700000000000)
+	idNow = func() time.Time { return now }
+	defer func() { idNow = time.Now }()
+
+	var uuids, ulids []string
+	for i := 0; i < 100; i++ {
+		if i == 50 {
+			now = now.Add(-time.Second)
+		}
+		uuids = append(uuids, NewUUIDv7().String())
+		ulids = append(ulids, NewULID().String())
+	}
+	for _, ids := range [][]string{uuids, ulids} {
+		if !sort.StringsAreSorted(ids) {
+			t.Errorf("IDs are not in order: %v", ids)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] == ids[i-1] {
+				t.Fatalf("%s was generated twice", ids[i])
+			}
+		}
+	}
+}
+
+func TestIDsJSON(t *testing.T) {
+	type record struct {
+		UUID UUID
+		ULID ULID
+	}
+	in := record{NewUUIDv4(), NewULID()}
+	data, err := json.Marshal(in)
+	expectNoErr(t, err)
+	expect(t, `{"UUID":"`+in.UUID.String()+`","ULID":"`+in.ULID.String()+`"}`, string(data), "JSON")
+
+	var out record
+	expectNoErr(t, json.Unmarshal(data, &out))
+	expect(t, in, out, "decoded record")
+
+	if json.Unmarshal([]byte(`{"UUID":"nope"}`), &out) == nil {
+		t.Error("Invalid UUID was decoded")
+	}
+}
+
+func TestNanoID(t *testing.T) {
+	id := NewNanoID()
+	expect(t, 21, len(id), "Nano ID length")
+	expect(t, true, IsValidNanoID(id), "valid Nano ID")
+	expect(t, false, IsValidNanoID("V1StGXR8_Z5jdHi6B-my!"), "Nano ID with a !")
+	expect(t, false, IsValidNanoID("V1StGXR8_Z5jdHi6B-my"), "short Nano ID")
+}
@@ -0,0 +1,293 @@
+package jgh
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Labels are the name/value pairs attached to a single measurement
+type Labels map[string]string
+
+// MetricsHook receives measurements from HTTPRequest (via
+// MetricsMiddleware) and Try. Implementations must be safe for concurrent
+// use.
+type MetricsHook interface {
+	// AddCounter adds delta to the named counter
+	AddCounter(name string, labels Labels, delta float64)
+	// ObserveHistogram records one observation in the named histogram
+	ObserveHistogram(name string, labels Labels, value float64)
+}
+
+// Metrics is where measurements are sent. It is nil (no metrics) by
+// default. Set it to a *PrometheusMetrics, or your own MetricsHook, to
+// turn metrics on.
+var Metrics MetricsHook
+
+// names of the metrics reported by this package
+const (
+	MetricHTTPRequests        = "jgh_http_requests_total"
+	MetricHTTPRequestDuration = "jgh_http_request_duration_seconds"
+	MetricHTTPRequestBytes    = "jgh_http_request_bytes_total"
+	MetricHTTPResponseBytes   = "jgh_http_response_bytes_total"
+	MetricTryCalls            = "jgh_try_calls_total"
+	MetricTryAttempts         = "jgh_try_attempts_total"
+	MetricTryRetries          = "jgh_try_retries_total"
+	MetricTryPanics           = "jgh_try_panics_total"
+)
+
+// addCounter is a nil-safe wrapper around Metrics.AddCounter
+func addCounter(name string, labels Labels, delta float64) {
+	if Metrics != nil {
+		Metrics.AddCounter(name, labels, delta)
+	}
+}
+
+// observeHistogram is a nil-safe wrapper around Metrics.ObserveHistogram
+func observeHistogram(name string, labels Labels, value float64) {
+	if Metrics != nil {
+		Metrics.ObserveHistogram(name, labels, value)
+	}
+}
+
+// StatusClass returns "1xx" through "5xx" for an HTTP status code, or
+// "unknown" if the code is out of range
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// MetricsMiddleware reports the number, duration and size of requests to
+// Metrics. Duration is measured until the response headers arrive.
+// Requests that fail without a response are counted with a status_class
+// of "error". It does nothing while Metrics is nil.
+func MetricsMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if Metrics == nil {
+			return next.RoundTrip(req)
+		}
+
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		duration := time.Since(start)
+
+		labels := Labels{"method": req.Method, "status_class": "error"}
+		if err == nil {
+			labels["status_class"] = StatusClass(resp.StatusCode)
+		}
+		addCounter(MetricHTTPRequests, labels, 1)
+		observeHistogram(MetricHTTPRequestDuration, labels, duration.Seconds())
+		if req.ContentLength > 0 {
+			addCounter(MetricHTTPRequestBytes, Labels{"method": req.Method}, float64(req.ContentLength))
+		}
+
+		// the response body hasn't been read yet, so count it as it is
+		if err == nil && resp.Body != nil {
+			resp.Body = &countingBody{
+				ReadCloser: resp.Body,
+				done: func(n int64) {
+					addCounter(MetricHTTPResponseBytes, Labels{"method": req.Method}, float64(n))
+				},
+			}
+		}
+
+		return resp, err
+	})
+}
+
+// countingBody counts the bytes read from a response body and reports
+// the total once, when the body hits EOF or is closed
+type countingBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.n) })
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.done(b.n) })
+	return b.ReadCloser.Close()
+}
+
+// DefaultBuckets are the histogram bucket upper bounds (in seconds) used
+// by NewPrometheusMetrics when none are given
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics is a MetricsHook that keeps measurements in memory and
+// exposes them in the Prometheus text format. It is an http.Handler, so it
+// can be mounted directly at /metrics.
+type PrometheusMetrics struct {
+	buckets    []float64
+	mutex      sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetrics makes an empty PrometheusMetrics. If buckets is nil,
+// DefaultBuckets is used.
+func NewPrometheusMetrics(buckets []float64) *PrometheusMetrics {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &PrometheusMetrics{
+		buckets:    buckets,
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+// AddCounter implements MetricsHook
+func (p *PrometheusMetrics) AddCounter(name string, labels Labels, delta float64) {
+	key := formatLabels(labels)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.counters[name] == nil {
+		p.counters[name] = make(map[string]float64)
+	}
+	p.counters[name][key] += delta
+}
+
+// ObserveHistogram implements MetricsHook
+func (p *PrometheusMetrics) ObserveHistogram(name string, labels Labels, value float64) {
+	key := formatLabels(labels)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.histograms[name] == nil {
+		p.histograms[name] = make(map[string]*histogram)
+	}
+	h := p.histograms[name][key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.histograms[name][key] = h
+	}
+	for i, upperBound := range p.buckets {
+		if value <= upperBound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// WriteTo writes every metric to w in the Prometheus text exposition
+// format. Output is sorted so that it is stable between calls.
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	p.mutex.Lock()
+	for _, name := range sortedKeys(p.counters) {
+		fmt.Fprintf(&b, "# TYPE %s counter\n", name)
+		series := p.counters[name]
+		for _, labels := range sortedKeys(series) {
+			fmt.Fprintf(&b, "%s%s %s\n", name, labels, formatFloat(series[labels]))
+		}
+	}
+	for _, name := range sortedKeys(p.histograms) {
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		series := p.histograms[name]
+		for _, labels := range sortedKeys(series) {
+			h := series[labels]
+			var cumulative uint64
+			for i, upperBound := range p.buckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, addLabel(labels, "le", formatFloat(upperBound)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, addLabel(labels, "le", "+Inf"), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, labels, h.count)
+		}
+	}
+	p.mutex.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w) // nolint: errcheck
+}
+
+// formatLabels turns labels into the {a="b",c="d"} form, sorted by name.
+// An empty set of labels formats as an empty string.
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labels[name])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// addLabel adds one more label to an already formatted label set
+func addLabel(formatted string, name string, value string) string {
+	pair := name + `="` + escapeLabelValue(value) + `"`
+	if formatted == "" {
+		return "{" + pair + "}"
+	}
+	return formatted[:len(formatted)-1] + "," + pair + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
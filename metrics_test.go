@@ -0,0 +1,59 @@
+package jgh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(404)
+		}
+		w.Write([]byte("hello")) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	metrics := NewPrometheusMetrics(nil)
+	oldMetrics := Metrics
+	defer func() { Metrics = oldMetrics }()
+	Metrics = metrics
+
+	HTTPRequest(nil, "POST", server.URL, "", "", nil, "four")
+	HTTPRequest(nil, "GET", server.URL+"/missing", "", "", nil, "")
+	Try(0, 3, false, "", func() bool {
+		panic("oops")
+	})
+
+	var out strings.Builder
+	metrics.WriteTo(&out) // nolint: errcheck
+	text := out.String()
+	t.Log(text)
+
+	for _, line := range []string{
+		"# TYPE jgh_http_requests_total counter",
+		`jgh_http_requests_total{method="POST",status_class="2xx"} 1`,
+		`jgh_http_requests_total{method="GET",status_class="4xx"} 1`,
+		`jgh_http_request_bytes_total{method="POST"} 4`,
+		`jgh_http_response_bytes_total{method="GET"} 5`,
+		"# TYPE jgh_http_request_duration_seconds histogram",
+		`jgh_http_request_duration_seconds_bucket{method="GET",status_class="4xx",le="+Inf"} 1`,
+		`jgh_http_request_duration_seconds_count{method="GET",status_class="4xx"} 1`,
+		"jgh_try_attempts_total 3",
+		"jgh_try_panics_total 3",
+		"jgh_try_retries_total 2",
+		`jgh_try_calls_total{result="failure"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Metrics output is missing %q", line)
+		}
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	expect(t, "2xx", StatusClass(204), "StatusClass(204)")
+	expect(t, "5xx", StatusClass(503), "StatusClass(503)")
+	expect(t, "unknown", StatusClass(42), "StatusClass(42)")
+}
@@ -0,0 +1,295 @@
+package jgh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// Middleware wraps an http.RoundTripper with some extra behavior. Each
+// middleware is handed the next RoundTripper in the chain and returns a
+// RoundTripper that (usually) calls it.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc lets an ordinary function be used as an
+// http.RoundTripper, the same way http.HandlerFunc does for handlers
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middlewares is the chain every request made by HTTPRequest (and
+// therefore RESTRequest) is sent through. The first middleware is the
+// outermost one, so it sees the request first and the response last.
+// Append to this to add behavior to every request, or replace it entirely
+// to drop the defaults.
+var Middlewares = DefaultMiddlewares()
+
+// DefaultMiddlewares returns a new copy of the chain that reproduces
+// HTTPRequest's historical behavior: logging, a default User-Agent and an
+// accurate Content-Length, along with response decompression, tracing and
+// metrics (which do nothing until Tracing and Metrics are set).
+func DefaultMiddlewares() []Middleware {
+	return []Middleware{
+		LoggingMiddleware,
+		TracingMiddleware,
+		UserAgentMiddleware,
+		ContentLengthMiddleware,
+		DecompressionMiddleware,
+		MetricsMiddleware,
+	}
+}
+
+// Chain wraps base in middlewares. The first middleware is the outermost.
+// If base is nil, http.DefaultTransport is used.
+func Chain(base http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// wrap from the inside out so that middlewares[0] ends up on the outside
+	rt := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// ClientWithMiddleware returns a shallow copy of client whose transport
+// has been wrapped in middlewares. The cookie jar, redirect policy and
+// timeout are shared with the original client. If client is nil, a new
+// client is made with HTTPClient(false, true).
+func ClientWithMiddleware(client *http.Client, middlewares ...Middleware) *http.Client {
+	if client == nil {
+		client = HTTPClient(false, true)
+	}
+	wrapped := *client
+	wrapped.Transport = Chain(client.Transport, middlewares...)
+	return &wrapped
+}
+
+// HeaderDefaultsMiddleware sets each of headers on the request, unless the
+// request already has a value for that header
+func HeaderDefaultsMiddleware(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for key, value := range headers {
+				if req.Header.Get(key) == "" {
+					req.Header.Set(key, value)
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// UserAgentMiddleware sets the User-Agent header to HTTPUserAgent if the
+// request does not already have one
+func UserAgentMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("User-Agent") == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("User-Agent", HTTPUserAgent)
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// ContentLengthMiddleware sets the Content-Length header to the actual
+// length of the request body, replacing any value set by the caller
+func ContentLengthMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.ContentLength > 0 {
+			req = req.Clone(req.Context())
+			req.Header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// BasicAuthMiddleware adds HTTP basic auth to every request. HTTPRequest
+// adds this for you when it is given a user or password.
+func BasicAuthMiddleware(user string, pass string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.SetBasicAuth(user, pass)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// BearerAuthMiddleware adds an "Authorization: Bearer <token>" header to
+// every request
+func BearerAuthMiddleware(token string) Middleware {
+	return HeaderOverrideMiddleware(map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+}
+
+// HeaderOverrideMiddleware sets each of headers on the request, replacing
+// any value the request already had
+func HeaderOverrideMiddleware(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// RequestIDMiddleware sets header (typically "X-Request-ID") to a new
+// random value for every request that does not already have one
+func RequestIDMiddleware(header string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(header, RandomString(16))
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// SigningMiddleware calls sign on a copy of each request before it is sent.
+// sign may add headers or query parameters (for example an HMAC of the
+// body). The request body, if any, can be read with RequestBody.
+func SigningMiddleware(sign func(req *http.Request) error) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			err := sign(req)
+			if err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// RetryMiddleware re-sends a request up to tries times, interval seconds
+// apart (see Try), until shouldRetry returns false or the request's context
+// is done. If shouldRetry is nil, requests are retried on transport errors
+// and 5xx responses. Requests with a body can only be retried if
+// req.GetBody is set, which http.NewRequest does for the common body types.
+func RetryMiddleware(interval int, tries int, shouldRetry func(resp *http.Response, err error) bool) Middleware {
+	if shouldRetry == nil {
+		shouldRetry = func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= 500
+		}
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (resp *http.Response, err error) {
+			// we can't rewind the body, so we can't retry
+			if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+				return next.RoundTrip(req)
+			}
+
+			attempt := 0
+			_, panicMsg := TryContext(req.Context(), interval, tries, false, "", func(context.Context) bool {
+				attempt++
+				attemptReq := req
+				if attempt > 1 {
+					// the last response will not be used, so don't leak it
+					if resp != nil {
+						resp.Body.Close() // nolint: errcheck
+						resp = nil
+					}
+					attemptReq = req.Clone(req.Context())
+					if req.GetBody != nil {
+						attemptReq.Body, err = req.GetBody()
+						if err != nil {
+							return true
+						}
+					}
+				}
+
+				resp, err = next.RoundTrip(attemptReq)
+				return !shouldRetry(resp, err) || req.Context().Err() != nil
+			})
+			if resp == nil && err == nil && panicMsg != nil {
+				err = fmt.Errorf("panic during request: %v", panicMsg)
+			}
+			return
+		})
+	}
+}
+
+// LoggingMiddleware logs the method and URL of each request, along with
+// the request and response bodies, to Logger
+func LoggingMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		Logger.Printf("HTTP %s %s", req.Method, req.URL)
+
+		reqBody, err := RequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(reqBody) > 0 {
+			Logger.Println("Request Body: ", string(reqBody))
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+
+		respBody, err := ResponseBody(resp)
+		if err != nil {
+			return resp, err
+		}
+		Logger.Println("Response Body: ", string(respBody))
+
+		return resp, nil
+	})
+}
+
+// RequestBody returns the body of req without consuming it. It uses
+// req.GetBody when available, otherwise the body is read and replaced
+// with an in-memory copy.
+func RequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close() // nolint: errcheck
+		return ioutil.ReadAll(body)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	req.Body.Close() // nolint: errcheck
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(bodyBytes)), nil
+	}
+	return bodyBytes, nil
+}
+
+// ResponseBody reads the whole body of resp and replaces it with an
+// in-memory copy, so it can still be read by the caller
+func ResponseBody(resp *http.Response) ([]byte, error) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil, nil
+	}
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close() // nolint: errcheck
+	resp.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+	return bodyBytes, err
+}
@@ -0,0 +1,104 @@
+package jgh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "base")
+		return &http.Response{StatusCode: 204, Body: http.NoBody}, nil
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.invalid/", nil)
+	_, err := Chain(base, mark("a"), mark("b")).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, []string{"a", "b", "base"}, order, "middleware order")
+}
+
+func TestHTTPRequestMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.UserAgent() + "|" + r.Header.Get("X-Request-ID") + "|" + user + ":" + pass)) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	oldMiddlewares := Middlewares
+	defer func() { Middlewares = oldMiddlewares }()
+	Middlewares = append(DefaultMiddlewares(), HeaderDefaultsMiddleware(map[string]string{
+		"X-Request-ID": "fixed",
+	}))
+
+	resp, status := HTTPRequest(nil, "GET", server.URL, "foo", "bar", nil, "")
+	if status != 200 {
+		t.Error("Status is not 200")
+	}
+	expect(t, HTTPUserAgent+"|fixed|foo:bar", resp, "response")
+
+	// caller supplied headers win over defaults
+	resp, _ = HTTPRequest(nil, "GET", server.URL, "", "", map[string]string{
+		"User-Agent":   "custom",
+		"X-Request-ID": "mine",
+	}, "")
+	expect(t, "custom|mine|:", resp, "response")
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	tries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tries++
+		body := ReadAll(r.Body)
+		if body != "payload" {
+			t.Errorf("attempt %d got body %q", tries, body)
+		}
+		if tries < 3 {
+			w.WriteHeader(503)
+		}
+	}))
+	defer server.Close()
+
+	client := ClientWithMiddleware(nil, RetryMiddleware(0, 5, nil))
+	_, status := HTTPRequest(client, "POST", server.URL, "", "", nil, "payload")
+	if status != 200 {
+		t.Errorf("Status is %d, not 200", status)
+	}
+	if tries != 3 {
+		t.Errorf("Expected 3 tries, got %d", tries)
+	}
+}
+
+func TestRetryMiddlewareCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	client := ClientWithMiddleware(nil, RetryMiddleware(10, 5, nil))
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close() // nolint: errcheck
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Cancelled request kept retrying for %s", time.Since(start))
+	}
+}
@@ -0,0 +1,160 @@
+package jgh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PanicError is the error for a function that panicked. It keeps the value
+// passed to panic and the stack of the goroutine that panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Format implements fmt.Formatter, so that %+v includes the stack trace
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		fmt.Fprintf(s, "%s\n%s", e.Error(), e.Stack)
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		fmt.Fprint(s, e.Error())
+	}
+}
+
+// Unwrap returns the panic value if it was an error (for example one
+// passed to PanicOnErr), so errors.Is and errors.As can see it
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// BatchError is returned by ParallelMap, ForEach and RESTBatch when one
+// or more items fail
+type BatchError struct {
+	Total int
+	// Failed maps the index of each failed item to its error
+	Failed map[int]error
+	// First is the index of the failed item that appears first in the input
+	First int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d items failed, first was #%d: %s", len(e.Failed), e.Total, e.First, e.Failed[e.First])
+}
+
+// Unwrap returns the errors of every failed item, in input order, so that
+// errors.Is and errors.As can look through them
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for i := 0; i < e.Total; i++ {
+		if err, failed := e.Failed[i]; failed {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ParallelMap calls f on every item, with at most concurrency calls running
+// at once (concurrency < 1 means one at a time), and returns the results in
+// the same order as items. A panic in f is recovered and recorded as a
+// *PanicError for that item, the same way Try recovers from panics.
+//
+// If failFast is true, the first failure cancels the context passed to
+// every other call, and items that have not started yet are skipped.
+// Otherwise every item is attempted. Cancelling ctx stops the work either
+// way. Skipped items have an error wrapping the context's error. If any
+// item failed, a *BatchError is returned along with the results.
+func ParallelMap[T any, R any](ctx context.Context, items []T, concurrency int, failFast bool, f func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(items); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					errs[i] = fmt.Errorf("not run: %w", context.Cause(ctx))
+					continue
+				}
+				results[i], errs[i] = callRecovered(ctx, items[i], f)
+				if errs[i] != nil && failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	// hand out work until we run out or are cancelled
+	next := 0
+feed:
+	for ; next < len(items); next++ {
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := next; i < len(items); i++ {
+		errs[i] = fmt.Errorf("not run: %w", context.Cause(ctx))
+	}
+
+	batchErr := &BatchError{Total: len(items), Failed: make(map[int]error), First: -1}
+	for i, err := range errs {
+		if err != nil {
+			batchErr.Failed[i] = err
+			if batchErr.First < 0 {
+				batchErr.First = i
+			}
+		}
+	}
+	if len(batchErr.Failed) > 0 {
+		return results, batchErr
+	}
+	return results, nil
+}
+
+// ForEach is ParallelMap for functions that don't produce a result
+func ForEach[T any](ctx context.Context, items []T, concurrency int, failFast bool, f func(ctx context.Context, item T) error) error {
+	_, err := ParallelMap(ctx, items, concurrency, failFast, func(ctx context.Context, item T) (struct{}, error) {
+		return struct{}{}, f(ctx, item)
+	})
+	return err
+}
+
+// callRecovered calls f, turning a panic into a *PanicError
+func callRecovered[T any, R any](ctx context.Context, item T, f func(ctx context.Context, item T) (R, error)) (result R, err error) {
+	err = Guard(func() (err error) {
+		result, err = f(ctx, item)
+		return
+	})
+	return
+}
+
+// panicToError converts a value from recover into an error. Errors are
+// returned as-is so that errors.Is and errors.As still work.
+func panicToError(panicMsg interface{}) error {
+	if err, isErr := panicMsg.(error); isErr {
+		return err
+	}
+	return errors.New(fmt.Sprint(panicMsg))
+}
@@ -0,0 +1,75 @@
+package jgh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParallelMap(t *testing.T) {
+	items := []string{"1", "2", "x", "4", "5"}
+	var running, maxRunning int32
+	results, err := ParallelMap(context.Background(), items, 2, false, func(ctx context.Context, item string) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		return strconv.Atoi(item)
+	})
+	if maxRunning > 2 {
+		t.Errorf("%d calls ran at once", maxRunning)
+	}
+	expect(t, []int{1, 2, 0, 4, 5}, results, "results")
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a BatchError, got %v", err)
+	}
+	expect(t, 2, batchErr.First, "first failed index")
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Error("BatchError does not unwrap to the item's error")
+	}
+}
+
+func TestParallelMapPanic(t *testing.T) {
+	err := ForEach(context.Background(), []int{1, 2, 3}, 3, false, func(ctx context.Context, item int) error {
+		if item == 2 {
+			PanicOnErr(io.ErrUnexpectedEOF)
+		}
+		return nil
+	})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected a PanicError, got %v", err)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Error("PanicError has no stack")
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("PanicError does not unwrap to the panic value")
+	}
+}
+
+func TestForEachFailFast(t *testing.T) {
+	items := make([]int, 100)
+	var calls int32
+	err := ForEach(context.Background(), items, 1, true, func(ctx context.Context, item int) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("nope")
+	})
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 100 {
+		t.Error("Skipped items were not reported as failed")
+	}
+	if !errors.Is(batchErr.Failed[99], context.Canceled) {
+		t.Error("Skipped item error does not wrap context.Canceled")
+	}
+}
@@ -0,0 +1,142 @@
+package jgh
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// Character classes for RandomPassword
+const (
+	UppercaseCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	LowercaseCharacters = "abcdefghijklmnopqrstuvwxyz"
+	DigitCharacters     = "0123456789"
+	// SymbolCharacters are the symbols most password policies accept
+	SymbolCharacters = "!#$%&*+-.:=?@^_~"
+	// LookalikeCharacters are easily confused with each other when a
+	// person reads or types them
+	LookalikeCharacters = "0Oo1Il|"
+)
+
+// PasswordPolicy describes the passwords RandomPassword makes
+type PasswordPolicy struct {
+	// The length, in characters (runes), is picked at random between
+	// MinLength and MaxLength. A MaxLength below MinLength means exactly
+	// MinLength.
+	MinLength int
+	MaxLength int
+	// every enabled class appears at least once
+	Upper   bool
+	Lower   bool
+	Digits  bool
+	Symbols bool
+	// SymbolSet replaces SymbolCharacters, for systems that only accept
+	// some symbols
+	SymbolSet string
+	// ExcludeLookalikes leaves out LookalikeCharacters
+	ExcludeLookalikes bool
+}
+
+// DefaultPasswordPolicy makes 16 character passwords that use every class
+// and no lookalikes
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:         16,
+	Upper:             true,
+	Lower:             true,
+	Digits:            true,
+	Symbols:           true,
+	ExcludeLookalikes: true,
+}
+
+// RandomPassword returns a random password following policy, using a
+// secure source of randomness. It panics if the policy can't be met, for
+// example if no classes are enabled or MinLength is shorter than the
+// number of classes.
+func RandomPassword(policy PasswordPolicy) string {
+	classes := policy.classes()
+	if len(classes) == 0 {
+		panic("RandomPassword: no character classes enabled")
+	}
+	length := policy.MinLength
+	if policy.MaxLength > policy.MinLength {
+		length += RandomIntn(policy.MaxLength - policy.MinLength + 1)
+	}
+	if length < len(classes) {
+		panic("RandomPassword: length is shorter than the number of character classes")
+	}
+
+	// one character from each class, then the rest from all of them
+	// (each character once, even if classes overlap), in a random order
+	password := make([]rune, 0, length)
+	var all []rune
+	seen := make(map[rune]bool)
+	for _, class := range classes {
+		password = append(password, RandomChoice(class))
+		for _, char := range class {
+			if !seen[char] {
+				seen[char] = true
+				all = append(all, char)
+			}
+		}
+	}
+	for len(password) < length {
+		password = append(password, RandomChoice(all))
+	}
+	RandomShuffle(len(password), func(i int, j int) {
+		password[i], password[j] = password[j], password[i]
+	})
+	return string(password)
+}
+
+// classes returns the characters of each enabled class
+func (policy PasswordPolicy) classes() [][]rune {
+	symbols := SymbolCharacters
+	if policy.SymbolSet != "" {
+		symbols = policy.SymbolSet
+	}
+	var classes [][]rune
+	for _, class := range []struct {
+		enabled    bool
+		characters string
+	}{
+		{policy.Upper, UppercaseCharacters},
+		{policy.Lower, LowercaseCharacters},
+		{policy.Digits, DigitCharacters},
+		{policy.Symbols, symbols},
+	} {
+		if !class.enabled {
+			continue
+		}
+		characters := class.characters
+		if policy.ExcludeLookalikes {
+			characters = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(LookalikeCharacters, r) {
+					return -1
+				}
+				return r
+			}, characters)
+		}
+		if characters == "" {
+			panic("RandomPassword: a character class is empty")
+		}
+		classes = append(classes, []rune(characters))
+	}
+	return classes
+}
+
+//go:embed passphrase_words.txt
+var passphraseWordList string
+
+// PassphraseWords is the word list for RandomPassphrase: 1296 short,
+// common English words, so 4 dice pick one word. Each word adds about
+// 10.3 bits of entropy.
+var PassphraseWords = strings.Fields(passphraseWordList)
+
+// RandomPassphrase returns words random words from PassphraseWords joined
+// by separator, diceware style. 6 words give about 62 bits of entropy.
+func RandomPassphrase(words int, separator string) string {
+	picked := make([]string, words)
+	for i := range picked {
+		picked[i] = RandomChoice(PassphraseWords)
+	}
+	return strings.Join(picked, separator)
+}
@@ -0,0 +1,98 @@
+package jgh
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRandomPassword(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		password := RandomPassword(DefaultPasswordPolicy)
+		expect(t, 16, len(password), "password length")
+		for _, class := range []string{UppercaseCharacters, LowercaseCharacters, DigitCharacters, SymbolCharacters} {
+			if !strings.ContainsAny(password, class) {
+				t.Fatalf("%q has none of %q", password, class)
+			}
+		}
+		if strings.ContainsAny(password, LookalikeCharacters) {
+			t.Fatalf("%q contains a lookalike character", password)
+		}
+	}
+
+	// the shortest possible password still has every class
+	password := RandomPassword(PasswordPolicy{MinLength: 2, Digits: true, Symbols: true, SymbolSet: "!"})
+	expect(t, true, strings.Contains(password, "!") && strings.ContainsAny(password, DigitCharacters), "password "+password+" has a digit and a !")
+}
+
+func TestRandomPasswordLengthRange(t *testing.T) {
+	lengths := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		password := RandomPassword(PasswordPolicy{MinLength: 8, MaxLength: 10, Lower: true})
+		if len(password) < 8 || len(password) > 10 {
+			t.Fatalf("%q is not between 8 and 10 characters", password)
+		}
+		lengths[len(password)] = true
+	}
+	expect(t, map[int]bool{8: true, 9: true, 10: true}, lengths, "lengths used")
+}
+
+func TestRandomPasswordInvalidPolicy(t *testing.T) {
+	for name, policy := range map[string]PasswordPolicy{
+		"no classes":    {MinLength: 10},
+		"too short":     {MinLength: 3, Upper: true, Lower: true, Digits: true, Symbols: true},
+		"empty class":   {MinLength: 4, Symbols: true, SymbolSet: "|", ExcludeLookalikes: true},
+		"zero length":   {Lower: true},
+		"max below min": {MinLength: 0, MaxLength: -1, Lower: true},
+	} {
+		if Catch(func() { RandomPassword(policy) }) == nil {
+			t.Errorf("Policy with %s was accepted", name)
+		}
+	}
+}
+
+func TestRandomPassphrase(t *testing.T) {
+	expect(t, 1296, len(PassphraseWords), "word list length")
+	unique := make(map[string]bool)
+	for _, word := range PassphraseWords {
+		unique[word] = true
+	}
+	expect(t, len(PassphraseWords), len(unique), "unique words")
+
+	words := strings.Split(RandomPassphrase(6, "-"), "-")
+	expect(t, 6, len(words), "words in passphrase")
+	for _, word := range words {
+		if !unique[word] {
+			t.Errorf("%q is not in the word list", word)
+		}
+	}
+}
+
+func TestRandomPasswordMultibyteSymbols(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		password := RandomPassword(PasswordPolicy{MinLength: 12, Lower: true, Symbols: true, SymbolSet: "€£"})
+		if !utf8.ValidString(password) {
+			t.Fatalf("%q is not valid UTF-8", password)
+		}
+		if utf8.RuneCountInString(password) != 12 {
+			t.Fatalf("%q is not 12 characters", password)
+		}
+		if !strings.ContainsAny(password, "€£") {
+			t.Fatalf("%q has no symbol", password)
+		}
+	}
+}
+
+func TestRandomPasswordOverlappingClasses(t *testing.T) {
+	// with "a" as a symbol as well as a lowercase letter, "a" should be
+	// picked about as often as any other letter, not twice as often
+	counts := make(map[rune]int)
+	for i := 0; i < 100; i++ {
+		for _, char := range RandomPassword(PasswordPolicy{MinLength: 202, Lower: true, Symbols: true, SymbolSet: "a"}) {
+			counts[char]++
+		}
+	}
+	if counts['a'] > counts['b']*3/2 {
+		t.Errorf("'a' was picked %d times and 'b' %d times", counts['a'], counts['b'])
+	}
+}
@@ -0,0 +1,78 @@
+package jgh
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// Preset alphabets for RandomStringFrom and RandomToken
+const (
+	// AlphanumericAlphabet is the alphabet used by RandomString
+	AlphanumericAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// HexAlphabet is lowercase hexadecimal
+	HexAlphabet = "0123456789abcdef"
+	// Base32CrockfordAlphabet is Douglas Crockford's base 32, which leaves
+	// out I, L, O and U
+	Base32CrockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// URLSafeAlphabet is the base64url alphabet (RFC 4648), which needs no
+	// escaping in URLs or filenames
+	URLSafeAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	// UnambiguousAlphabet is AlphanumericAlphabet without 0, O, I and l,
+	// which are easily confused when read by a person
+	UnambiguousAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ123456789"
+)
+
+// RandomStringFrom returns a random string of n characters (runes) picked
+// from alphabet, which may contain multibyte runes. Like RandomString, it
+// uses a secure source of randomness.
+func RandomStringFrom(n int, alphabet string) string {
+	runes := []rune(alphabet)
+	if len(runes) == 0 {
+		panic("RandomStringFrom: empty alphabet")
+	}
+
+	// build the result as bytes when we can, since it's much faster
+	if len(runes) == len(alphabet) {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[secureSource.Uint64n(uint64(len(alphabet)))]
+		}
+		return string(b)
+	}
+
+	r := make([]rune, n)
+	for i := range r {
+		r[i] = runes[secureSource.Uint64n(uint64(len(runes)))]
+	}
+	return string(r)
+}
+
+// TokenLength returns how many characters from alphabet are needed for a
+// token with at least bits bits of entropy
+func TokenLength(bits float64, alphabet string) int {
+	checkAlphabet(alphabet)
+	size := utf8.RuneCountInString(alphabet)
+	return int(math.Ceil(bits / math.Log2(float64(size))))
+}
+
+// RandomToken returns a random string from alphabet that is long enough
+// to have at least bits bits of entropy. For example 128 bits needs 22
+// characters of AlphanumericAlphabet, or 32 of HexAlphabet.
+func RandomToken(bits float64, alphabet string) string {
+	return RandomStringFrom(TokenLength(bits, alphabet), alphabet)
+}
+
+// checkAlphabet panics if alphabet can't be used to calculate entropy,
+// because it is too short or repeats a character
+func checkAlphabet(alphabet string) {
+	seen := make(map[rune]bool)
+	for _, char := range alphabet {
+		if seen[char] {
+			panic("alphabet contains " + string(char) + " more than once")
+		}
+		seen[char] = true
+	}
+	if len(seen) < 2 {
+		panic("alphabet must contain at least 2 characters")
+	}
+}
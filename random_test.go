@@ -0,0 +1,141 @@
+package jgh
+
+import (
+	cryptoRand "crypto/rand"
+	"encoding/binary"
+	mathRand "math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRandomStringFrom(t *testing.T) {
+	s := RandomStringFrom(100, HexAlphabet)
+	if len(s) != 100 || strings.Trim(s, HexAlphabet) != "" {
+		t.Errorf("%q is not 100 hex characters", s)
+	}
+
+	s = RandomStringFrom(50, "🎲🃏♠")
+	if utf8.RuneCountInString(s) != 50 || strings.Trim(s, "🎲🃏♠") != "" {
+		t.Errorf("%q is not 50 characters from a multibyte alphabet", s)
+	}
+
+	s = RandomStringFrom(1000, UnambiguousAlphabet)
+	if strings.ContainsAny(s, "0OIl") {
+		t.Error("Unambiguous string contained an ambiguous character")
+	}
+}
+
+func TestRandomToken(t *testing.T) {
+	expect(t, 22, TokenLength(128, AlphanumericAlphabet), "alphanumeric length for 128 bits")
+	expect(t, 32, TokenLength(128, HexAlphabet), "hex length for 128 bits")
+	expect(t, 26, TokenLength(128, Base32CrockfordAlphabet), "base32 length for 128 bits")
+	expect(t, 22, len(RandomToken(128, URLSafeAlphabet)), "URL safe token length")
+
+	success, _ := Try(0, 1, false, "", func() bool {
+		RandomToken(128, "aab")
+		return true
+	})
+	if success {
+		t.Error("Alphabet with a repeated character was accepted")
+	}
+}
+
+func TestCryptoSourceUint64n(t *testing.T) {
+	counts := make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		counts[secureSource.Uint64n(3)]++
+	}
+	for value, count := range counts {
+		if count < 800 || count > 1200 {
+			t.Errorf("%d was picked %d times out of 3000", value, count)
+		}
+	}
+
+	// the largest n, where nothing needs to be rejected
+	secureSource.Uint64n(1 << 63)
+	expect(t, uint64(0), secureSource.Uint64n(1), "Uint64n(1)")
+}
+
+func TestCryptoSourceConcurrent(t *testing.T) {
+	// run with -race to check the buffer is shared safely
+	var wg sync.WaitGroup
+	seen := make([]map[uint64]bool, 8)
+	for g := range seen {
+		seen[g] = make(map[uint64]bool)
+		wg.Add(1)
+		go func(seen map[uint64]bool) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				seen[secureSource.Uint64()] = true
+			}
+		}(seen[g])
+	}
+	wg.Wait()
+
+	all := make(map[uint64]bool)
+	for _, s := range seen {
+		for v := range s {
+			if all[v] {
+				t.Fatal("The same value was handed out twice")
+			}
+			all[v] = true
+		}
+	}
+	expect(t, 8000, len(all), "number of distinct values")
+}
+
+// unbufferedSource is how cryptoSource used to work, reading crypto/rand
+// for every number
+type unbufferedSource struct{}
+
+func (unbufferedSource) Seed(seed int64) {}
+
+func (s unbufferedSource) Int63() int64 {
+	return int64(s.Uint64() & ^uint64(1<<63))
+}
+
+func (unbufferedSource) Uint64() (v uint64) {
+	PanicOnErr(binary.Read(cryptoRand.Reader, binary.BigEndian, &v))
+	return v
+}
+
+func BenchmarkCryptoSource(b *testing.B) {
+	source := newCryptoSource()
+	for i := 0; i < b.N; i++ {
+		source.Uint64()
+	}
+}
+
+func BenchmarkCryptoSourceUnbuffered(b *testing.B) {
+	source := unbufferedSource{}
+	for i := 0; i < b.N; i++ {
+		source.Uint64()
+	}
+}
+
+func BenchmarkCryptoSourceParallel(b *testing.B) {
+	source := newCryptoSource()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			source.Uint64()
+		}
+	})
+}
+
+func BenchmarkRandomString(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		RandomString(32)
+	}
+}
+
+func BenchmarkRandomStringUnbuffered(b *testing.B) {
+	random := mathRand.New(unbufferedSource{})
+	for i := 0; i < b.N; i++ {
+		s := make([]byte, 32)
+		for j := range s {
+			s[j] = AlphanumericAlphabet[random.Intn(len(AlphanumericAlphabet))]
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package jgh
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Attempt records the outcome of a single call made by Retry
+type Attempt struct {
+	Number   int
+	Start    time.Time
+	Duration time.Duration
+	// Err is nil if the attempt succeeded. If it panicked, Err is a
+	// *PanicError.
+	Err error
+}
+
+// RetryError is returned by Retry when f never succeeded. It lists every
+// attempt that was made.
+type RetryError struct {
+	Attempts []Attempt
+	// Permanent is true if Retry stopped early because isPermanent
+	// returned true for the last error
+	Permanent bool
+	// Cancelled is set to the context's error if Retry stopped early
+	// because its context was done
+	Cancelled error
+}
+
+func (e *RetryError) Error() string {
+	var b strings.Builder
+	switch {
+	case e.Permanent:
+		fmt.Fprintf(&b, "permanent error after %d attempts:", len(e.Attempts))
+	case e.Cancelled != nil:
+		fmt.Fprintf(&b, "%s after %d attempts:", e.Cancelled, len(e.Attempts))
+	default:
+		fmt.Fprintf(&b, "gave up after %d attempts:", len(e.Attempts))
+	}
+	for _, attempt := range e.Attempts {
+		fmt.Fprintf(
+			&b, "\n\tattempt %d at %s (took %s): %s",
+			attempt.Number, attempt.Start.Format("15:04:05.000"), attempt.Duration, attempt.Err,
+		)
+	}
+	return b.String()
+}
+
+// Last returns the error from the final attempt
+func (e *RetryError) Last() error {
+	if len(e.Attempts) == 0 {
+		return e.Cancelled
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+// Unwrap returns the error from every attempt, and the context's error if
+// Retry was cancelled, so errors.Is and errors.As can look through them
+func (e *RetryError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Attempts)+1)
+	for _, attempt := range e.Attempts {
+		errs = append(errs, attempt.Err)
+	}
+	if e.Cancelled != nil {
+		errs = append(errs, e.Cancelled)
+	}
+	return errs
+}
+
+// Retry is like Try, but for functions that return an error. f is called
+// at interval second intervals up to tries times (forever if tries is
+// negative) until it returns nil. Panics in f are recovered and treated
+// as a failed attempt. If isPermanent is not nil and returns true for an
+// error, Retry stops without trying again. If msg is not empty, each
+// attempt is logged the same way Try logs.
+//
+// Retry returns nil on success, otherwise a *RetryError with the history
+// of every attempt.
+func Retry(interval int, tries int, msg string, isPermanent func(err error) bool, f func() error) error {
+	return RetryContext(context.Background(), interval, tries, msg, isPermanent, func(context.Context) error {
+		return f()
+	})
+}
+
+// RetryContext is like Retry, but stops once ctx is done. A span is
+// started (using Tracing) around the whole call and each attempt, and f
+// is given the context of its attempt's span. DefaultTryHooks are called
+// as attempts fail.
+func RetryContext(ctx context.Context, interval int, tries int, msg string, isPermanent func(err error) bool, f func(ctx context.Context) error) error {
+	return RetryWithHooks(ctx, interval, tries, msg, isPermanent, DefaultTryHooks, f)
+}
+
+// RetryWithHooks is like RetryContext, but calls hooks instead of
+// DefaultTryHooks as attempts fail
+func RetryWithHooks(ctx context.Context, interval int, tries int, msg string, isPermanent func(err error) bool, hooks TryHooks, f func(ctx context.Context) error) error {
+	// if tries is negitive, we retry forever
+	infinite := tries < 0
+	loggingEnabled := len(msg) > 0
+	delay := time.Duration(interval) * time.Second
+
+	ctx, span := Tracing.Start(ctx, "Retry")
+	defer span.End()
+	if loggingEnabled {
+		span.SetAttribute("try.msg", msg)
+	}
+
+	retryErr := &RetryError{}
+	for number := 1; infinite || number <= tries; number++ {
+		if loggingEnabled {
+			if infinite {
+				Logger.Printf("%s (try %d)", msg, number)
+			} else {
+				Logger.Printf("%s (will retry up to %d times)", msg, tries-number+1)
+			}
+		}
+
+		attempt := retryAttempt(ctx, number, f)
+		span.SetAttribute("try.attempts", number)
+		if attempt.Err == nil {
+			addCounter(MetricTryCalls, Labels{"result": "success"}, 1)
+			return nil
+		}
+
+		panicErr, panicked := attempt.Err.(*PanicError)
+		if panicked {
+			hooks.panic(TryEvent{Msg: msg, Attempt: number, Err: attempt.Err})
+		}
+		if loggingEnabled {
+			if panicked {
+				Logger.Printf("Panic while %s: %v\n%s", msg, panicErr.Value, panicErr.Stack)
+			} else {
+				Logger.Printf("Error while %s: %s", msg, attempt.Err)
+			}
+		}
+		retryErr.Attempts = append(retryErr.Attempts, attempt)
+
+		if isPermanent != nil && isPermanent(attempt.Err) {
+			retryErr.Permanent = true
+			break
+		}
+
+		// no point in sleeping if we are not going to retry f()
+		if infinite || number < tries {
+			addCounter(MetricTryRetries, nil, 1)
+			hooks.retry(TryEvent{Msg: msg, Attempt: number, NextDelay: delay, Err: attempt.Err})
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				retryErr.Cancelled = ctx.Err()
+			}
+			if retryErr.Cancelled != nil {
+				break
+			}
+		}
+	}
+
+	span.RecordError(retryErr)
+	addCounter(MetricTryCalls, Labels{"result": "failure"}, 1)
+	hooks.giveUp(TryEvent{Msg: msg, Attempt: len(retryErr.Attempts), Err: retryErr})
+	return retryErr
+}
+
+// retryAttempt makes a single call to f, timing it and recovering from
+// any panic
+func retryAttempt(ctx context.Context, number int, f func(ctx context.Context) error) (attempt Attempt) {
+	attemptCtx, span := Tracing.Start(ctx, "Retry attempt")
+	span.SetAttribute("try.attempt", number)
+	defer span.End()
+
+	attempt.Number = number
+	attempt.Start = time.Now()
+	defer func() {
+		if panicMsg := recover(); panicMsg != nil {
+			addCounter(MetricTryPanics, nil, 1)
+			attempt.Err = &PanicError{Value: panicMsg, Stack: debug.Stack()}
+		}
+		attempt.Duration = time.Since(attempt.Start)
+		if attempt.Err != nil {
+			span.RecordError(attempt.Err)
+		}
+	}()
+
+	addCounter(MetricTryAttempts, nil, 1)
+	attempt.Err = f(attemptCtx)
+	return
+}
@@ -0,0 +1,79 @@
+package jgh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	tries := 0
+	err := Retry(0, 5, "Fake Testing Function", nil, func() error {
+		tries++
+		if tries < 3 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	expect(t, 3, tries, "tries")
+
+	tries = 0
+	err = Retry(0, 3, "", nil, func() error {
+		tries++
+		if tries == 2 {
+			panic("AAAAAH!")
+		}
+		return io.EOF
+	})
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("Expected a RetryError, got %v", err)
+	}
+	t.Log(err)
+	expect(t, 3, len(retryErr.Attempts), "number of attempts")
+	var panicErr *PanicError
+	if !errors.As(retryErr.Attempts[1].Err, &panicErr) || panicErr.Value != "AAAAAH!" {
+		t.Error("Panic was not recorded for attempt 2")
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Error("RetryError does not unwrap to the attempts' errors")
+	}
+	if strings.Count(err.Error(), "\n") != 3 {
+		t.Error("RetryError message does not list every attempt")
+	}
+}
+
+func TestRetryPermanent(t *testing.T) {
+	tries := 0
+	err := Retry(0, 5, "", func(err error) bool {
+		return err == io.ErrClosedPipe
+	}, func() error {
+		tries++
+		return io.ErrClosedPipe
+	})
+	expect(t, 1, tries, "tries")
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || !retryErr.Permanent {
+		t.Error("Error was not reported as permanent")
+	}
+	expect(t, io.ErrClosedPipe, retryErr.Last(), "last error")
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tries := 0
+	err := RetryContext(ctx, 60, -1, "", nil, func(ctx context.Context) error {
+		tries++
+		cancel()
+		return io.EOF
+	})
+	expect(t, 1, tries, "tries")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package jgh
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// Scrypt derives a keyLen byte key from password and salt with the scrypt
+// key derivation function (RFC 7914). Unlike PBKDF2 it needs a lot of
+// memory (128 * N * r bytes), which makes guessing passwords with GPUs
+// or custom hardware expensive. N must be a power of 2 greater than 1.
+//
+// This is the same algorithm as golang.org/x/crypto/scrypt, which this
+// package can't depend on.
+func Scrypt(password []byte, salt []byte, N int, r int, p int, keyLen int) ([]byte, error) {
+	if N <= 1 || N&(N-1) != 0 {
+		return nil, errors.New("scrypt: N must be a power of 2 greater than 1")
+	}
+	if r <= 0 || p <= 0 || uint64(r)*uint64(p) >= 1<<30 || r > (1<<31-1)/128/p || r > (1<<31-1)/256 || N > (1<<31-1)/128/r {
+		return nil, errors.New("scrypt: parameters are too large")
+	}
+
+	blockWords := 32 * r
+	xy := make([]uint32, 2*blockWords)
+	v := make([]uint32, N*blockWords)
+	b, err := pbkdf2.Key(sha256.New, string(password), salt, 1, p*128*r)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < p; i++ {
+		scryptROMix(b[i*128*r:], r, N, v, xy)
+	}
+	return pbkdf2.Key(sha256.New, string(password), b, 1, keyLen)
+}
+
+// scryptROMix mixes one 128 * r byte block of b in place, using v as the
+// large scratch memory
+func scryptROMix(b []byte, r int, N int, v []uint32, xy []uint32) {
+	var tmp [16]uint32
+	blockWords := 32 * r
+	x := xy[:blockWords]
+	y := xy[blockWords:]
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	for i := 0; i < N; i += 2 {
+		copy(v[i*blockWords:], x)
+		scryptBlockMix(&tmp, x, y, r)
+		copy(v[(i+1)*blockWords:], y)
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	for i := 0; i < N; i += 2 {
+		j := int(scryptIntegerify(x, r) & uint64(N-1))
+		xorWords(x, v[j*blockWords:])
+		scryptBlockMix(&tmp, x, y, r)
+		j = int(scryptIntegerify(y, r) & uint64(N-1))
+		xorWords(y, v[j*blockWords:])
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	for i, word := range x {
+		binary.LittleEndian.PutUint32(b[i*4:], word)
+	}
+}
+
+// scryptBlockMix is BlockMix from RFC 7914, with the even output blocks
+// stored first and the odd ones after them
+func scryptBlockMix(tmp *[16]uint32, in []uint32, out []uint32, r int) {
+	copy(tmp[:], in[(2*r-1)*16:])
+	for i := 0; i < 2*r; i += 2 {
+		salsa208XOR(tmp, in[i*16:], out[i*8:])
+		salsa208XOR(tmp, in[i*16+16:], out[i*8+r*16:])
+	}
+}
+
+// scryptIntegerify returns the first 64 bits of the last 64 byte block
+func scryptIntegerify(b []uint32, r int) uint64 {
+	j := (2*r - 1) * 16
+	return uint64(b[j]) | uint64(b[j+1])<<32
+}
+
+func xorWords(dst []uint32, src []uint32) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// salsaQuarterRounds are the word indexes (a, b, c, d) of each quarter
+// round of Salsa20: four column rounds followed by four row rounds
+var salsaQuarterRounds = [8][4]int{
+	{0, 4, 8, 12}, {5, 9, 13, 1}, {10, 14, 2, 6}, {15, 3, 7, 11},
+	{0, 1, 2, 3}, {5, 6, 7, 4}, {10, 11, 8, 9}, {15, 12, 13, 14},
+}
+
+// salsa208XOR sets tmp and out to the Salsa20/8 core of tmp XOR in
+func salsa208XOR(tmp *[16]uint32, in []uint32, out []uint32) {
+	var x [16]uint32
+	for i := range x {
+		x[i] = tmp[i] ^ in[i]
+	}
+	w := x
+	for round := 0; round < 8; round += 2 {
+		for _, q := range salsaQuarterRounds {
+			x[q[1]] ^= bits.RotateLeft32(x[q[0]]+x[q[3]], 7)
+			x[q[2]] ^= bits.RotateLeft32(x[q[1]]+x[q[0]], 9)
+			x[q[3]] ^= bits.RotateLeft32(x[q[2]]+x[q[1]], 13)
+			x[q[0]] ^= bits.RotateLeft32(x[q[3]]+x[q[2]], 18)
+		}
+	}
+	for i := range x {
+		x[i] += w[i]
+		tmp[i] = x[i]
+		out[i] = x[i]
+	}
+}
@@ -0,0 +1,32 @@
+package jgh
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestScrypt(t *testing.T) {
+	// test vectors from RFC 7914
+	for _, test := range []struct {
+		password string
+		salt     string
+		N, r, p  int
+		expected string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	} {
+		key, err := Scrypt([]byte(test.password), []byte(test.salt), test.N, test.r, test.p, 64)
+		expectNoErr(t, err)
+		expect(t, test.expected, hex.EncodeToString(key), "scrypt of "+test.password)
+	}
+
+	for _, N := range []int{0, 1, 3, 1000} {
+		if _, err := Scrypt([]byte("password"), []byte("salt"), N, 8, 1, 32); err == nil {
+			t.Errorf("N = %d was accepted", N)
+		}
+	}
+	if _, err := Scrypt([]byte("password"), []byte("salt"), 16, 1<<20, 1<<10, 32); err == nil {
+		t.Error("Huge r and p were accepted")
+	}
+}
@@ -0,0 +1,115 @@
+package jgh
+
+import (
+	"math"
+)
+
+// The functions in this file are a replacement for Rand that can be used
+// from any number of goroutines at once. Like Rand they use crypto/rand,
+// and integers in a range are picked without modulo bias.
+
+// RandomIntn returns a random number in [0, n). It panics if n <= 0.
+func RandomIntn(n int) int {
+	if n <= 0 {
+		panic("RandomIntn: n must be greater than 0")
+	}
+	return int(secureSource.Uint64n(uint64(n)))
+}
+
+// RandomInt63 returns a random non-negative int64
+func RandomInt63() int64 {
+	return secureSource.Int63()
+}
+
+// RandomFloat64 returns a random number in [0.0, 1.0)
+func RandomFloat64() float64 {
+	// 53 random bits fill the mantissa of a float64 exactly
+	return float64(secureSource.Uint64()>>11) / (1 << 53)
+}
+
+// RandomShuffle puts n elements in a random order, using swap to swap the
+// elements with indexes i and j, like math/rand.Shuffle
+func RandomShuffle(n int, swap func(i int, j int)) {
+	if n < 0 {
+		panic("RandomShuffle: n must not be negative")
+	}
+	// Fisher-Yates
+	for i := n - 1; i > 0; i-- {
+		swap(i, RandomIntn(i+1))
+	}
+}
+
+// RandomPerm returns a random permutation of the numbers [0, n)
+func RandomPerm(n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	RandomShuffle(n, func(i int, j int) {
+		perm[i], perm[j] = perm[j], perm[i]
+	})
+	return perm
+}
+
+// RandomChoice returns a random element of items, which must not be empty
+func RandomChoice[T any](items []T) T {
+	return items[RandomIntn(len(items))]
+}
+
+// RandomWeightedChoice returns a random element of items, where
+// items[i] is picked with a probability of weights[i] divided by the sum
+// of all the weights. Weights must not be negative, and at least one must
+// be more than 0.
+func RandomWeightedChoice[T any](items []T, weights []float64) T {
+	if len(items) != len(weights) {
+		panic("RandomWeightedChoice: items and weights have different lengths")
+	}
+	total := 0.0
+	for _, weight := range weights {
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			panic("RandomWeightedChoice: weights must be finite and not negative")
+		}
+		total += weight
+	}
+	if total <= 0 {
+		panic("RandomWeightedChoice: all weights are 0")
+	}
+
+	target := RandomFloat64() * total
+	last := 0
+	for i, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+		if target < weight {
+			return items[i]
+		}
+		target -= weight
+		last = i
+	}
+	// rounding errors can leave a tiny bit of target over, which belongs
+	// to the last item that could be picked
+	return items[last]
+}
+
+// RandomSample returns k different elements of items (different by
+// position, items may contain duplicates) in a random order, without
+// changing items. It panics if k is more than len(items).
+func RandomSample[T any](items []T, k int) []T {
+	if k < 0 || k > len(items) {
+		panic("RandomSample: k must be between 0 and the number of items")
+	}
+	// a partial Fisher-Yates shuffle of the indexes, so only k random
+	// numbers are needed
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sample := make([]T, k)
+	for i := 0; i < k; i++ {
+		j := i + RandomIntn(len(indexes)-i)
+		indexes[i], indexes[j] = indexes[j], indexes[i]
+		sample[i] = items[indexes[i]]
+	}
+	return sample
+}
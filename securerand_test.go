@@ -0,0 +1,123 @@
+package jgh
+
+import (
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestRandomIntn(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		n := RandomIntn(10)
+		if n < 0 || n >= 10 {
+			t.Fatalf("RandomIntn(10) returned %d", n)
+		}
+	}
+	expect(t, 0, RandomIntn(1), "RandomIntn(1)")
+
+	if Catch(func() { RandomIntn(0) }) == nil {
+		t.Error("RandomIntn(0) didn't panic")
+	}
+}
+
+func TestRandomFloat64(t *testing.T) {
+	sum := 0.0
+	for i := 0; i < 10000; i++ {
+		f := RandomFloat64()
+		if f < 0 || f >= 1 {
+			t.Fatalf("RandomFloat64 returned %v", f)
+		}
+		sum += f
+	}
+	if mean := sum / 10000; mean < 0.45 || mean > 0.55 {
+		t.Errorf("Mean of RandomFloat64 was %v", mean)
+	}
+}
+
+func TestRandomPerm(t *testing.T) {
+	perm := RandomPerm(100)
+	sorted := append([]int(nil), perm...)
+	sort.Ints(sorted)
+	for i, n := range sorted {
+		if i != n {
+			t.Fatalf("%v is not a permutation of 0 to 99", perm)
+		}
+	}
+	expect(t, []int{}, RandomPerm(0), "RandomPerm(0)")
+}
+
+func TestRandomShuffle(t *testing.T) {
+	// every order of 3 elements should come up
+	orders := make(map[string]int)
+	for i := 0; i < 600; i++ {
+		items := []byte("abc")
+		RandomShuffle(len(items), func(i int, j int) {
+			items[i], items[j] = items[j], items[i]
+		})
+		orders[string(items)]++
+	}
+	expect(t, 6, len(orders), "number of different orders")
+	for order, count := range orders {
+		if count < 50 {
+			t.Errorf("%s only came up %d times out of 600", order, count)
+		}
+	}
+}
+
+func TestRandomWeightedChoice(t *testing.T) {
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[RandomWeightedChoice([]string{"a", "b", "never"}, []float64{1, 3, 0})]++
+	}
+	expect(t, 0, counts["never"], "picks of an item with weight 0")
+	if counts["b"] < 2800 || counts["b"] > 3200 {
+		t.Errorf("Item with 3/4 of the weight was picked %d times out of 4000", counts["b"])
+	}
+
+	if Catch(func() { RandomWeightedChoice([]int{1, 2}, []float64{0, 0}) }) == nil {
+		t.Error("All zero weights didn't panic")
+	}
+	if Catch(func() { RandomWeightedChoice([]int{1, 2}, []float64{1, -1}) }) == nil {
+		t.Error("Negative weight didn't panic")
+	}
+	if Catch(func() { RandomWeightedChoice([]int{1, 2}, []float64{1}) }) == nil {
+		t.Error("Mismatched lengths didn't panic")
+	}
+}
+
+func TestRandomSample(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	sample := RandomSample(items, 5)
+	expect(t, 5, len(sample), "sample size")
+	seen := make(map[int]bool)
+	for _, item := range sample {
+		if seen[item] {
+			t.Errorf("%v contains %d twice", sample, item)
+		}
+		seen[item] = true
+	}
+	expect(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, items, "items after sampling")
+
+	expect(t, 10, len(RandomSample(items, 10)), "sample of every item")
+	if Catch(func() { RandomSample(items, 11) }) == nil {
+		t.Error("Sample larger than items didn't panic")
+	}
+}
+
+func TestRandomConcurrent(t *testing.T) {
+	// run with -race
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				RandomIntn(100)
+				RandomFloat64()
+				RandomPerm(10)
+				RandomSample([]string{"a", "b", "c"}, 2)
+			}
+		}()
+	}
+	wg.Wait()
+}
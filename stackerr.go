@@ -0,0 +1,112 @@
+package jgh
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// StackError is an error with a message, an optional cause, and the stack
+// trace of where it was created. Unlike RenameErr, wrapping an error this
+// way keeps the original, so errors.Is and errors.As still find it.
+//
+// Formatting with %v or %s prints the message and the cause on one line.
+// %+v prints each error in the chain on its own line, along with the
+// stack trace of every StackError in the chain.
+type StackError struct {
+	Msg   string
+	Cause error
+	stack []uintptr
+}
+
+// NewError makes a StackError with no cause
+func NewError(msg string) error {
+	return newStackError(nil, msg)
+}
+
+// Wrap returns err wrapped in a StackError with msg, or nil if err is nil
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return newStackError(err, msg)
+}
+
+// Wrapf is Wrap with a format string
+func Wrapf(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return newStackError(err, fmt.Sprintf(format, args...))
+}
+
+func newStackError(cause error, msg string) *StackError {
+	// skip runtime.Callers, newStackError and NewError/Wrap/Wrapf
+	stack := make([]uintptr, 64)
+	n := runtime.Callers(3, stack)
+	return &StackError{Msg: msg, Cause: cause, stack: stack[:n]}
+}
+
+func (e *StackError) Error() string {
+	if e.Cause == nil {
+		return e.Msg
+	}
+	return e.Msg + ": " + e.Cause.Error()
+}
+
+// Unwrap returns the cause
+func (e *StackError) Unwrap() error {
+	return e.Cause
+}
+
+// StackTrace returns the frames of the stack where e was created,
+// innermost first
+func (e *StackError) StackTrace() []runtime.Frame {
+	var frames []runtime.Frame
+	callersFrames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := callersFrames.Next()
+		frames = append(frames, frame)
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// Format implements fmt.Formatter, to support %+v
+func (e *StackError) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		io.WriteString(s, e.detailed()) // nolint: errcheck
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		io.WriteString(s, e.Error()) // nolint: errcheck
+	}
+}
+
+// detailed lists every error in the chain, with stack traces
+func (e *StackError) detailed() string {
+	var b strings.Builder
+	var err error = e
+	for i := 0; err != nil; i++ {
+		if i > 0 {
+			b.WriteString("\ncaused by: ")
+		}
+		stackErr, isStackErr := err.(*StackError)
+		if !isStackErr {
+			// we can't print just this layer of a foreign error, so print
+			// the rest of the chain as one message
+			b.WriteString(err.Error())
+			break
+		}
+		b.WriteString(stackErr.Msg)
+		for _, frame := range stackErr.StackTrace() {
+			fmt.Fprintf(&b, "\n\tat %s (%s:%d)", frame.Function, frame.File, frame.Line)
+		}
+		err = stackErr.Cause
+	}
+	return b.String()
+}
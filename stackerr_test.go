@@ -0,0 +1,45 @@
+package jgh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStackError(t *testing.T) {
+	if Wrap(nil, "nothing") != nil {
+		t.Error("Wrap(nil) returned an error")
+	}
+
+	err := Wrapf(Wrap(io.EOF, "reading header"), "loading %s", "config.json")
+	expect(t, "loading config.json: reading header: EOF", err.Error(), "error message")
+	expect(t, "loading config.json: reading header: EOF", fmt.Sprintf("%v", err), "%v")
+	if !errors.Is(err, io.EOF) {
+		t.Error("StackError does not unwrap to its cause")
+	}
+
+	var stackErr *StackError
+	if !errors.As(err, &stackErr) {
+		t.Fatal("errors.As did not find the StackError")
+	}
+	if !strings.HasSuffix(stackErr.StackTrace()[0].Function, "TestStackError") {
+		t.Errorf("Stack trace starts at %s", stackErr.StackTrace()[0].Function)
+	}
+
+	detailed := fmt.Sprintf("%+v", err)
+	t.Log(detailed)
+	for _, expected := range []string{
+		"loading config.json\n\tat ",
+		"\ncaused by: reading header\n\tat ",
+		"\ncaused by: EOF",
+		"stackerr_test.go:",
+	} {
+		if !strings.Contains(detailed, expected) {
+			t.Errorf("%%+v output is missing %q", expected)
+		}
+	}
+
+	expect(t, "no cause", NewError("no cause").Error(), "NewError message")
+}
@@ -0,0 +1,144 @@
+package jgh
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoStatus is returned by ParseStatus and StatusOf when there is no
+// status code to find
+var ErrNoStatus = errors.New("no HTTP status code found")
+
+// ErrMalformedStatus is returned (wrapped with the offending text) by
+// ParseStatus and StatusOf when something is in the place of a status
+// code, but isn't a valid one, as in "HTTP 4040"
+var ErrMalformedStatus = errors.New("malformed HTTP status code")
+
+// HTTPStatusError is an error that knows the HTTP status code it came
+// from, like StatusError. StatusOf looks for one in the error chain.
+type HTTPStatusError interface {
+	error
+	HTTPStatus() int
+}
+
+// StatusCode is an HTTP status code found by ParseStatus or StatusOf
+type StatusCode int
+
+// Text returns the standard text for the code, like "Not Found"
+func (s StatusCode) Text() string {
+	return http.StatusText(int(s))
+}
+
+// Class returns "1xx" through "5xx" (see StatusClass)
+func (s StatusCode) Class() string {
+	return StatusClass(int(s))
+}
+
+// IsClientError returns whether the code is a 4xx
+func (s StatusCode) IsClientError() bool {
+	return s >= 400 && s <= 499
+}
+
+// IsServerError returns whether the code is a 5xx
+func (s StatusCode) IsServerError() bool {
+	return s >= 500 && s <= 599
+}
+
+// String returns the code and its text, like "404 Not Found"
+func (s StatusCode) String() string {
+	return strings.TrimSpace(strconv.Itoa(int(s)) + " " + s.Text())
+}
+
+var (
+	// a word that introduces a status code, like "HTTP 404", "HTTP/1.1
+	// 503", "status: 404", "status_code=500" or "error 502"
+	statusKeywordRegexp = regexp.MustCompile(`(?i)\b(HTTP(?:/\d(?:\.\d)?)?|status(?:[ _-]?code)?|code|error)\s*[:=#]?\s*(\d\w*)`)
+	// a number at the very start, as Status reads it
+	statusLeadingRegexp = regexp.MustCompile(`^\s*(\d+)\b`)
+	// any three digit number, which only counts if it is followed by its
+	// status text, as in "got 404 Not Found"
+	statusNumberRegexp = regexp.MustCompile(`\b(\d{3})\b`)
+)
+
+// ParseStatus finds an HTTP status code anywhere in s, which is usually an
+// error message. It understands, in this order of preference:
+//
+//   - a code after a word like HTTP, status or error: "HTTP 404: not
+//     found", "request failed: error 503", "status_code=429"
+//   - a code at the start, as Status reads it: "404 unable to locate"
+//   - a code followed by its status text: "server said 502 Bad Gateway"
+//
+// If none of these are found it returns ErrNoStatus. If a number after
+// HTTP or status, or at the start, isn't a valid status code (100 to
+// 599), it returns an error wrapping ErrMalformedStatus. Numbers after
+// code or error that aren't status codes are ignored, since those words
+// are common in other messages.
+func ParseStatus(s string) (StatusCode, error) {
+	var malformed string
+	check := func(candidate string) (StatusCode, bool) {
+		code, err := strconv.Atoi(candidate)
+		if err != nil || code < 100 || code > 599 {
+			if malformed == "" {
+				malformed = candidate
+			}
+			return 0, false
+		}
+		return StatusCode(code), true
+	}
+
+	for _, match := range statusKeywordRegexp.FindAllStringSubmatch(s, -1) {
+		keyword := strings.ToLower(match[1])
+		if keyword == "code" || keyword == "error" {
+			// these are just as likely to come before an exit code or a
+			// count ("exit code 1", "error 2 of 5"), so only a valid
+			// status code counts, and anything else isn't malformed
+			if code, err := strconv.Atoi(match[2]); err == nil && len(match[2]) == 3 && code >= 100 && code <= 599 {
+				return StatusCode(code), nil
+			}
+			continue
+		}
+		if code, ok := check(match[2]); ok {
+			return code, nil
+		}
+	}
+	if match := statusLeadingRegexp.FindStringSubmatch(s); match != nil {
+		if code, ok := check(match[1]); ok {
+			return code, nil
+		}
+	}
+	for _, match := range statusNumberRegexp.FindAllStringSubmatchIndex(s, -1) {
+		code, _ := strconv.Atoi(s[match[2]:match[3]])
+		text := http.StatusText(code)
+		rest := strings.TrimLeft(s[match[3]:], " \t:-")
+		if text != "" && len(rest) >= len(text) && strings.EqualFold(rest[:len(text)], text) {
+			return StatusCode(code), nil
+		}
+	}
+
+	if malformed != "" {
+		return 0, fmt.Errorf("%w: %q", ErrMalformedStatus, malformed)
+	}
+	return 0, ErrNoStatus
+}
+
+// StatusOf finds the HTTP status code behind err. It first looks through
+// the error chain for an HTTPStatusError (such as a StatusError from
+// RESTBatch), and otherwise parses the error message with ParseStatus.
+func StatusOf(err error) (StatusCode, error) {
+	if err == nil {
+		return 0, ErrNoStatus
+	}
+	var statusErr HTTPStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.HTTPStatus()
+		if code < 100 || code > 599 {
+			return 0, fmt.Errorf("%w: %d", ErrMalformedStatus, code)
+		}
+		return StatusCode(code), nil
+	}
+	return ParseStatus(err.Error())
+}
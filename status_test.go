@@ -0,0 +1,74 @@
+package jgh
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	for input, expected := range map[string]StatusCode{
+		"404 unable to locate your thing":     404,
+		"HTTP 404: not found":                 404,
+		"error 503":                           503,
+		"request failed: HTTP/1.1 502":        502,
+		"upstream returned status: 429":       429,
+		"status_code=500 after 3 retries":     500,
+		"server said 502 Bad Gateway":         502,
+		"got 404 not found in 250 ms":         404,
+		"took 250 ms, then HTTP 418":          418,
+		"Error: code 401 (token expired)":     401,
+		"panic: 403 Forbidden (GET /secrets)": 403,
+	} {
+		status, err := ParseStatus(input)
+		expect(t, nil, err, "error parsing "+input)
+		expect(t, expected, status, input)
+	}
+
+	for _, input := range []string{"", "I'm a teapot", "took 250 ms", "error: connection refused", "retrying in 100 seconds", "exit code 1", "error 2 of 5 retries", "connection error 10054", "error 5xx", "code 600"} {
+		_, err := ParseStatus(input)
+		expect(t, ErrNoStatus, err, "error parsing "+input)
+	}
+
+	for _, input := range []string{"HTTP 4040", "status: 42", "700 things went wrong", "status_code=5xx"} {
+		_, err := ParseStatus(input)
+		if !errors.Is(err, ErrMalformedStatus) {
+			t.Errorf("Parsing %q gave %v, expected ErrMalformedStatus", input, err)
+		}
+	}
+
+	// a valid code wins over an earlier malformed one
+	status, err := ParseStatus("status 42, then HTTP 503")
+	expect(t, nil, err, "error with a malformed and a valid code")
+	expect(t, StatusCode(503), status, "status with a malformed and a valid code")
+}
+
+func TestStatusOf(t *testing.T) {
+	statusErr := StatusError{Method: "GET", URL: "http://example.com/", Status: 503}
+	status, err := StatusOf(fmt.Errorf("fetching config: %w", statusErr))
+	expect(t, nil, err, "error for a wrapped StatusError")
+	expect(t, StatusCode(503), status, "status of a wrapped StatusError")
+
+	// found in one of several errors
+	batchErr := &BatchError{Total: 2, Failed: map[int]error{1: statusErr}, First: 1}
+	status, _ = StatusOf(batchErr)
+	expect(t, StatusCode(503), status, "status of a BatchError")
+
+	status, _ = StatusOf(Wrap(errors.New("HTTP 404: not found"), "loading user"))
+	expect(t, StatusCode(404), status, "status parsed from the message")
+
+	_, err = StatusOf(nil)
+	expect(t, ErrNoStatus, err, "error for nil")
+	_, err = StatusOf(StatusError{Status: 0})
+	expect(t, true, errors.Is(err, ErrMalformedStatus), "invalid code from HTTPStatus is malformed")
+}
+
+func TestStatusCode(t *testing.T) {
+	expect(t, "Not Found", StatusCode(404).Text(), "text")
+	expect(t, "4xx", StatusCode(404).Class(), "class")
+	expect(t, "404 Not Found", StatusCode(404).String(), "string")
+	expect(t, true, StatusCode(404).IsClientError(), "404 is a client error")
+	expect(t, false, StatusCode(404).IsServerError(), "404 is a server error")
+	expect(t, true, StatusCode(503).IsServerError(), "503 is a server error")
+	expect(t, "299", StatusCode(299).String(), "string of a code without text")
+}
@@ -0,0 +1,265 @@
+package jgh
+
+import (
+	"context"
+	cryptoRand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a whole distributed trace
+type TraceID [16]byte
+
+// SpanID identifies a single span within a trace
+type SpanID [8]byte
+
+// SpanContext is the part of a span that is propagated to other services
+// in the traceparent and tracestate headers
+// (https://www.w3.org/TR/trace-context/)
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether sc has both a trace ID and a span ID
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Span is a single timed operation. It is deliberately a small subset of
+// the OpenTelemetry span API so it is easy to adapt.
+type Span interface {
+	SpanContext() SpanContext
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans. The returned context carries the new span, so
+// spans started from it become its children.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Tracing is the Tracer used by TracingMiddleware and TryContext. The
+// default NoopTracer records nothing, but still forwards any incoming trace
+// context. Replace it with an adapter for OpenTelemetry, or a
+// SimpleTracer, to record spans.
+var Tracing Tracer = NoopTracer{}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc as the current
+// (parent) span context
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, or an
+// invalid SpanContext if there isn't one
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// NoopTracer is a Tracer whose spans do nothing. Its spans report the
+// parent's span context, so a trace that came in is still propagated out.
+type NoopTracer struct{}
+
+// Start implements Tracer
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{sc: SpanContextFromContext(ctx)}
+}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext                   { return s.sc }
+func (s noopSpan) SetAttribute(key string, value interface{}) {}
+func (s noopSpan) RecordError(err error)                      {}
+func (s noopSpan) End()                                       {}
+
+// SimpleTracer is a minimal real Tracer. It generates IDs, continues
+// traces found in the context and calls OnEnd with each span as it
+// finishes. It is meant for logging and tests, not as a replacement for a
+// tracing SDK.
+type SimpleTracer struct {
+	OnEnd func(span *SimpleSpan)
+}
+
+// Start implements Tracer
+func (t SimpleTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	span := &SimpleSpan{
+		Name:       name,
+		Parent:     parent.SpanID,
+		StartTime:  time.Now(),
+		Attributes: make(map[string]interface{}),
+		onEnd:      t.OnEnd,
+	}
+	span.Context.Sampled = true
+	if parent.IsValid() {
+		span.Context.TraceID = parent.TraceID
+		span.Context.Sampled = parent.Sampled
+		span.Context.TraceState = parent.TraceState
+	} else {
+		cryptoRand.Read(span.Context.TraceID[:]) // nolint: errcheck
+	}
+	cryptoRand.Read(span.Context.SpanID[:]) // nolint: errcheck
+	return ContextWithSpanContext(ctx, span.Context), span
+}
+
+// SimpleSpan is the Span produced by SimpleTracer
+type SimpleSpan struct {
+	Name       string
+	Context    SpanContext
+	Parent     SpanID
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+	Errors     []error
+
+	mutex sync.Mutex
+	onEnd func(span *SimpleSpan)
+}
+
+// SpanContext implements Span
+func (s *SimpleSpan) SpanContext() SpanContext {
+	return s.Context
+}
+
+// SetAttribute implements Span
+func (s *SimpleSpan) SetAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Attributes[key] = value
+}
+
+// RecordError implements Span
+func (s *SimpleSpan) RecordError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+// End implements Span
+func (s *SimpleSpan) End() {
+	s.mutex.Lock()
+	s.EndTime = time.Now()
+	s.mutex.Unlock()
+	if s.onEnd != nil {
+		s.onEnd(s)
+	}
+}
+
+// ErrInvalidTraceparent is returned by ParseTraceparent for a header that
+// does not follow the W3C trace context format
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// FormatTraceparent formats sc as a W3C traceparent header value
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value. The trace state
+// is not part of traceparent, so it is left empty.
+func ParseTraceparent(traceparent string) (sc SpanContext, err error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, ErrInvalidTraceparent
+	}
+	// version 00 has exactly 4 fields, future versions may add more
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, ErrInvalidTraceparent
+	}
+	if !isLowerHex(parts[0]) || !isLowerHex(parts[1]) || !isLowerHex(parts[2]) || !isLowerHex(parts[3]) {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, ErrInvalidTraceparent
+	}
+
+	hex.Decode(sc.TraceID[:], []byte(parts[1])) // nolint: errcheck
+	hex.Decode(sc.SpanID[:], []byte(parts[2]))  // nolint: errcheck
+	var flags [1]byte
+	hex.Decode(flags[:], []byte(parts[3])) // nolint: errcheck
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, char := range s {
+		if !(char >= '0' && char <= '9') && !(char >= 'a' && char <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// InjectTraceContext sets the traceparent and tracestate headers from sc.
+// Nothing is set if sc is not valid.
+func InjectTraceContext(header http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	header.Set("traceparent", FormatTraceparent(sc))
+	if sc.TraceState != "" {
+		header.Set("tracestate", sc.TraceState)
+	} else {
+		header.Del("tracestate")
+	}
+}
+
+// ExtractTraceContext reads the traceparent and tracestate headers of an
+// incoming request and returns a copy of ctx carrying that span context,
+// so spans started from it join the caller's trace. If the headers are
+// missing or malformed, ctx is returned unchanged.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get("traceparent"))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = header.Get("tracestate")
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// TracingMiddleware starts a span (using Tracing) around each request and
+// propagates it to the server in the traceparent and tracestate headers
+func TracingMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx, span := Tracing.Start(req.Context(), "HTTP "+req.Method)
+		defer span.End()
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.url", req.URL.String())
+
+		req = req.Clone(ctx)
+		InjectTraceContext(req.Header, span.SpanContext())
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			span.RecordError(err)
+			return resp, err
+		}
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			span.RecordError(fmt.Errorf("server returned %s", resp.Status))
+		}
+		return resp, nil
+	})
+}
@@ -0,0 +1,90 @@
+package jgh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Sampled {
+		t.Error("Sampled flag was not parsed")
+	}
+	expect(t, header, FormatTraceparent(sc), "formatted traceparent")
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceparent(bad); err != ErrInvalidTraceparent {
+			t.Errorf("ParseTraceparent(%q) did not fail", bad)
+		}
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	var mutex sync.Mutex
+	var spans []*SimpleSpan
+	oldTracing := Tracing
+	defer func() { Tracing = oldTracing }()
+	Tracing = SimpleTracer{OnEnd: func(span *SimpleSpan) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		spans = append(spans, span)
+	}}
+
+	parent, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	expectNoErr(t, err)
+	ctx := ContextWithSpanContext(context.Background(), parent)
+
+	TryContext(ctx, 0, 1, false, "", func(ctx context.Context) bool {
+		_, status := HTTPRequestContext(ctx, nil, "GET", server.URL, "", "", nil, "")
+		return status == 200
+	})
+
+	// HTTP span, attempt span, Try span, in the order they ended
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	httpSpan, attemptSpan, trySpan := spans[0], spans[1], spans[2]
+	expect(t, FormatTraceparent(httpSpan.Context), received, "traceparent sent to server")
+	expect(t, parent.TraceID, httpSpan.Context.TraceID, "trace ID")
+	expect(t, parent.SpanID, trySpan.Parent, "Try span parent")
+	expect(t, trySpan.Context.SpanID, attemptSpan.Parent, "attempt span parent")
+	expect(t, attemptSpan.Context.SpanID, httpSpan.Parent, "HTTP span parent")
+	expect(t, 200, httpSpan.Attributes["http.status_code"], "http.status_code attribute")
+}
+
+func TestNoopTracerPropagates(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	incoming := http.Header{}
+	incoming.Set("traceparent", header)
+	ctx := ExtractTraceContext(context.Background(), incoming)
+
+	HTTPRequestContext(ctx, nil, "GET", server.URL, "", "", nil, "")
+	expect(t, header, received, "traceparent sent to server")
+}
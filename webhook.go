@@ -0,0 +1,252 @@
+package jgh
+
+import (
+	"crypto"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by WebhookVerifier
+var (
+	// ErrWebhookSignature means the signature is missing or doesn't match
+	// any of the secrets
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+	// ErrWebhookExpired means the signature is valid but its timestamp is
+	// outside the tolerance window
+	ErrWebhookExpired = errors.New("webhook timestamp outside tolerance")
+	// ErrWebhookReplay means the webhook has already been received
+	ErrWebhookReplay = errors.New("webhook replayed")
+)
+
+// WebhookScheme checks the signature of a webhook against one secret. It
+// returns the time the webhook was signed, or the zero time if the scheme
+// has no timestamp, and ErrWebhookSignature if the signature is wrong.
+type WebhookScheme func(header http.Header, body []byte, secret []byte) (signedAt time.Time, err error)
+
+// HMACWebhookScheme checks a header holding the HMAC of the body, in hex
+// or base 64, after an optional prefix such as "sha1="
+func HMACWebhookScheme(headerName string, hash crypto.Hash, prefix string) WebhookScheme {
+	return func(header http.Header, body []byte, secret []byte) (time.Time, error) {
+		signature, found := strings.CutPrefix(header.Get(headerName), prefix)
+		if !found || !VerifyHMACString(hash, secret, body, signature) {
+			return time.Time{}, ErrWebhookSignature
+		}
+		return time.Time{}, nil
+	}
+}
+
+// GitHubWebhookScheme checks the X-Hub-Signature-256 header GitHub sends,
+// "sha256=" followed by the hex HMAC-SHA256 of the body
+func GitHubWebhookScheme() WebhookScheme {
+	return HMACWebhookScheme("X-Hub-Signature-256", crypto.SHA256, "sha256=")
+}
+
+// TimestampedWebhookScheme checks a header in the format Stripe uses:
+// "t=<unix time>,v1=<hex signature>", where the signature is the
+// HMAC-SHA256 of the timestamp, a dot and the body. Signing the
+// timestamp stops an old webhook being replayed with a new time. There
+// may be several v1 signatures, for example while a secret is rotated.
+func TimestampedWebhookScheme(headerName string) WebhookScheme {
+	return func(header http.Header, body []byte, secret []byte) (time.Time, error) {
+		var timestamp string
+		var signatures []string
+		for _, field := range strings.Split(header.Get(headerName), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				signatures = append(signatures, value)
+			}
+		}
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return time.Time{}, ErrWebhookSignature
+		}
+		expected := HMACString(crypto.SHA256, secret, timestamp+"."+string(body))
+		for _, signature := range signatures {
+			if decoded, ok := decodeDigest(signature, crypto.SHA256.Size()); ok && expected.Equal(decoded) {
+				return time.Unix(seconds, 0), nil
+			}
+		}
+		return time.Time{}, ErrWebhookSignature
+	}
+}
+
+// SignTimestampedWebhook returns the header value TimestampedWebhookScheme
+// expects for body signed with secret at signedAt
+func SignTimestampedWebhook(secret []byte, body []byte, signedAt time.Time) string {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + HMACString(crypto.SHA256, secret, timestamp+"."+string(body)).Hex()
+}
+
+// DefaultWebhookTolerance is how far a signed timestamp may be from the
+// current time, if WebhookVerifier.Tolerance is not set
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// DefaultWebhookMaxBodySize is the largest body WebhookVerifier.Handler
+// reads, if WebhookVerifier.MaxBodySize is not set
+const DefaultWebhookMaxBodySize = 1 << 20
+
+// WebhookVerifier checks the signatures of incoming webhooks
+type WebhookVerifier struct {
+	Scheme WebhookScheme
+	// a signature from any of Secrets is accepted, so secrets can be
+	// rotated without downtime
+	Secrets [][]byte
+	// Tolerance is how old (or how far in the future, to allow for clock
+	// skew) a timestamped signature may be
+	Tolerance time.Duration
+	// Nonces, if set, rejects webhooks that have been seen before. Its
+	// TTL should be at least Tolerance, since older webhooks are rejected
+	// anyway.
+	Nonces *NonceCache
+	// Webhooks are identified by their signed time and body. NonceHeader
+	// is an optional header with a unique ID for each delivery, for
+	// example X-GitHub-Delivery, which also has to be new. Since it is
+	// usually not signed, a new ID doesn't make an old body acceptable.
+	NonceHeader string
+	// MaxBodySize is the largest body Handler accepts, since the whole
+	// body is read before the signature can be checked
+	MaxBodySize int64
+}
+
+// NewWebhookVerifier returns a verifier for scheme accepting any of
+// secrets, with the default tolerance and no replay protection
+func NewWebhookVerifier(scheme WebhookScheme, secrets ...string) *WebhookVerifier {
+	verifier := &WebhookVerifier{Scheme: scheme}
+	for _, secret := range secrets {
+		verifier.Secrets = append(verifier.Secrets, []byte(secret))
+	}
+	return verifier
+}
+
+// Verify returns nil if the webhook with header and body is genuine, or
+// one of ErrWebhookSignature, ErrWebhookExpired and ErrWebhookReplay
+func (v *WebhookVerifier) Verify(header http.Header, body []byte) error {
+	signedAt, err := v.checkSignature(header, body)
+	if err != nil {
+		return err
+	}
+
+	if !signedAt.IsZero() {
+		tolerance := v.Tolerance
+		if tolerance == 0 {
+			tolerance = DefaultWebhookTolerance
+		}
+		age := time.Since(signedAt)
+		if age > tolerance || age < -tolerance {
+			return ErrWebhookExpired
+		}
+	}
+
+	// only remember webhooks with a valid signature, so nobody else can
+	// fill the cache
+	if v.Nonces != nil {
+		// the delivery header usually isn't signed, so it can only add to
+		// the signed time and body, never replace them
+		replayed := v.Nonces.Seen("signed:" + strconv.FormatInt(signedAt.Unix(), 10) + ":" + HashBytes(crypto.SHA256, body).Hex())
+		if delivery := header.Get(v.NonceHeader); v.NonceHeader != "" && delivery != "" {
+			replayed = v.Nonces.Seen("delivery:"+delivery) || replayed
+		}
+		if replayed {
+			return ErrWebhookReplay
+		}
+	}
+	return nil
+}
+
+func (v *WebhookVerifier) checkSignature(header http.Header, body []byte) (signedAt time.Time, err error) {
+	for _, secret := range v.Secrets {
+		signedAt, err = v.Scheme(header, body, secret)
+		if err == nil {
+			return signedAt, nil
+		}
+	}
+	return time.Time{}, ErrWebhookSignature
+}
+
+// VerifyRequest verifies an incoming webhook request and returns its body.
+// The request body can still be read afterwards.
+func (v *WebhookVerifier) VerifyRequest(req *http.Request) ([]byte, error) {
+	body, err := RequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	return body, v.Verify(req.Header, body)
+}
+
+// Handler wraps handler so that it only sees verified webhooks. Others
+// are answered with 401 Unauthorized, 409 Conflict for replays, or 413
+// Request Entity Too Large for bodies over MaxBodySize.
+func (v *WebhookVerifier) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxBodySize := v.MaxBodySize
+		if maxBodySize == 0 {
+			maxBodySize = DefaultWebhookMaxBodySize
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		_, err := v.VerifyRequest(r)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case err == ErrWebhookReplay:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err == ErrWebhookSignature || err == ErrWebhookExpired:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			handler.ServeHTTP(w, r)
+		}
+	})
+}
+
+// NonceCache remembers values for a while, to detect replayed requests.
+// It is safe for concurrent use. The zero value is ready to use once TTL
+// is set.
+type NonceCache struct {
+	TTL   time.Duration
+	mutex sync.Mutex
+	seen  map[string]time.Time
+	// now is the clock, replaced in tests
+	now func() time.Time
+}
+
+// NewNonceCache returns a cache that remembers nonces for ttl
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{TTL: ttl, seen: make(map[string]time.Time), now: time.Now}
+}
+
+// Seen records nonce, and returns whether it had already been recorded
+// within the TTL
+func (c *NonceCache) Seen(nonce string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+	now := c.now()
+	if expires, found := c.seen[nonce]; found && now.Before(expires) {
+		return true
+	}
+
+	// drop expired nonces now and then, so the map doesn't keep growing
+	if len(c.seen) >= 64 && len(c.seen)%64 == 0 {
+		for key, expires := range c.seen {
+			if !now.Before(expires) {
+				delete(c.seen, key)
+			}
+		}
+	}
+	c.seen[nonce] = now.Add(c.TTL)
+	return false
+}
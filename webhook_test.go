@@ -0,0 +1,171 @@
+package jgh
+
+import (
+	"crypto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubWebhook(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+HMAC(crypto.SHA256, []byte("new secret"), body).Hex())
+
+	verifier := NewWebhookVerifier(GitHubWebhookScheme(), "old secret", "new secret")
+	expect(t, nil, verifier.Verify(header, body), "error with a valid signature")
+	expect(t, ErrWebhookSignature, verifier.Verify(header, []byte(`{"action":"closed"}`)), "error with a changed body")
+	expect(t, ErrWebhookSignature, verifier.Verify(http.Header{}, body), "error without a signature")
+
+	header.Set("X-Hub-Signature-256", HMAC(crypto.SHA256, []byte("new secret"), body).Hex())
+	expect(t, ErrWebhookSignature, verifier.Verify(header, body), "error without the sha256= prefix")
+}
+
+func TestHMACWebhook(t *testing.T) {
+	body := []byte("payload")
+	header := http.Header{}
+	header.Set("X-Signature", HMAC(crypto.SHA1, []byte("secret"), body).Base64())
+	verifier := NewWebhookVerifier(HMACWebhookScheme("X-Signature", crypto.SHA1, ""), "secret")
+	expect(t, nil, verifier.Verify(header, body), "error with a base64 signature")
+}
+
+func TestTimestampedWebhook(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	verifier := NewWebhookVerifier(TimestampedWebhookScheme("Stripe-Signature"), "secret")
+
+	header := http.Header{}
+	header.Set("Stripe-Signature", SignTimestampedWebhook([]byte("secret"), body, time.Now()))
+	expect(t, nil, verifier.Verify(header, body), "error with a fresh signature")
+
+	// several signatures, one of which is right
+	header.Set("Stripe-Signature", SignTimestampedWebhook([]byte("other"), body, time.Now())+", v1="+strings.Split(SignTimestampedWebhook([]byte("secret"), body, time.Now()), "v1=")[1])
+	expect(t, nil, verifier.Verify(header, body), "error with two signatures")
+
+	header.Set("Stripe-Signature", SignTimestampedWebhook([]byte("secret"), body, time.Now().Add(-10*time.Minute)))
+	expect(t, ErrWebhookExpired, verifier.Verify(header, body), "error with an old signature")
+	header.Set("Stripe-Signature", SignTimestampedWebhook([]byte("secret"), body, time.Now().Add(10*time.Minute)))
+	expect(t, ErrWebhookExpired, verifier.Verify(header, body), "error with a future signature")
+	verifier.Tolerance = time.Hour
+	expect(t, nil, verifier.Verify(header, body), "error with a longer tolerance")
+
+	// changing the timestamp breaks the signature
+	signature := SignTimestampedWebhook([]byte("secret"), body, time.Unix(1000, 0))
+	header.Set("Stripe-Signature", strings.Replace(signature, "t=1000", "t=1001", 1))
+	expect(t, ErrWebhookSignature, verifier.Verify(header, body), "error with a changed timestamp")
+	header.Set("Stripe-Signature", "v1=abc")
+	expect(t, ErrWebhookSignature, verifier.Verify(header, body), "error without a timestamp")
+}
+
+func TestWebhookReplay(t *testing.T) {
+	body := []byte("payload")
+	verifier := NewWebhookVerifier(GitHubWebhookScheme(), "secret")
+	verifier.Nonces = NewNonceCache(time.Hour)
+	sign := func(body []byte) http.Header {
+		header := http.Header{}
+		header.Set("X-Hub-Signature-256", "sha256="+HMAC(crypto.SHA256, []byte("secret"), body).Hex())
+		return header
+	}
+	header := sign(body)
+
+	if err := verifier.Verify(header, body); err != nil {
+		t.Errorf("First delivery gave %v", err)
+	}
+	if err := verifier.Verify(header, body); err != ErrWebhookReplay {
+		t.Errorf("Second delivery gave %v, expected ErrWebhookReplay", err)
+	}
+
+	// the delivery ID isn't signed, so changing it doesn't allow a replay
+	verifier.NonceHeader = "X-GitHub-Delivery"
+	for _, delivery := range []string{"1", "2", "3"} {
+		header.Set("X-GitHub-Delivery", delivery)
+		if err := verifier.Verify(header, body); err != ErrWebhookReplay {
+			t.Errorf("Replay with delivery %s gave %v, expected ErrWebhookReplay", delivery, err)
+		}
+	}
+
+	// but a new body with a delivery ID that was already used is a replay
+	other := []byte("other payload")
+	header = sign(other)
+	header.Set("X-GitHub-Delivery", "4")
+	if err := verifier.Verify(header, other); err != nil {
+		t.Errorf("New body with delivery 4 gave %v", err)
+	}
+	another := []byte("another payload")
+	header = sign(another)
+	header.Set("X-GitHub-Delivery", "4")
+	if err := verifier.Verify(header, another); err != ErrWebhookReplay {
+		t.Errorf("Reused delivery 4 gave %v, expected ErrWebhookReplay", err)
+	}
+
+	// invalid webhooks aren't remembered
+	fresh := []byte("fresh payload")
+	header = sign(fresh)
+	header.Set("X-GitHub-Delivery", "5")
+	if err := verifier.Verify(header, []byte("forged")); err != ErrWebhookSignature {
+		t.Errorf("Forged webhook gave %v, expected ErrWebhookSignature", err)
+	}
+	if err := verifier.Verify(header, fresh); err != nil {
+		t.Errorf("Genuine webhook after a forged one gave %v", err)
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewNonceCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	expect(t, false, cache.Seen("a"), "a seen the first time")
+	expect(t, true, cache.Seen("a"), "a seen the second time")
+	now = now.Add(time.Minute)
+	expect(t, false, cache.Seen("a"), "a seen after the TTL")
+
+	for i := 0; i < 200; i++ {
+		cache.Seen(RandomString(8))
+	}
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 64; i++ {
+		cache.Seen(RandomString(8))
+	}
+	if len(cache.seen) > 128 {
+		t.Errorf("Cache still holds %d nonces", len(cache.seen))
+	}
+
+	zero := &NonceCache{TTL: time.Minute}
+	expect(t, false, zero.Seen("a"), "a seen the first time by a zero cache")
+	expect(t, true, zero.Seen("a"), "a seen the second time by a zero cache")
+}
+
+func TestWebhookHandler(t *testing.T) {
+	verifier := NewWebhookVerifier(GitHubWebhookScheme(), "secret")
+	verifier.Nonces = NewNonceCache(time.Hour)
+	server := httptest.NewServer(verifier.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body) // nolint: errcheck
+	})))
+	defer server.Close()
+
+	send := func(body string, signature string) (int, string) {
+		req, _ := http.NewRequest("POST", server.URL, strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", signature)
+		resp, err := http.DefaultClient.Do(req)
+		expectNoErr(t, err)
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody)
+	}
+	signature := "sha256=" + HMACString(crypto.SHA256, []byte("secret"), "hello").Hex()
+	status, body := send("hello", signature)
+	expect(t, 200, status, "status with a valid signature")
+	expect(t, "hello", body, "body seen by the handler")
+	status, _ = send("hello", signature)
+	expect(t, 409, status, "status for a replay")
+	status, _ = send("goodbye", signature)
+	expect(t, 401, status, "status with an invalid signature")
+
+	verifier.MaxBodySize = 4
+	status, _ = send("hello", signature)
+	expect(t, 413, status, "status with a body over MaxBodySize")
+}